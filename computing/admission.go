@@ -0,0 +1,122 @@
+package computing
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+)
+
+// deny is the rejection of a space by the admission policy, for the reason reported on the job
+func deny(format string, args ...interface{}) error {
+	return fmt.Errorf("rejected by the admission policy, "+format, args...)
+}
+
+// admitDockerfile checks the instructions and the base images of the Dockerfile of a space before it is built
+func admitDockerfile(dockerfilePath string) error {
+	policy := conf.GetConfig().Admission
+	if !policy.Enable {
+		return nil
+	}
+
+	instructions, err := docker.ParseDockerfile(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	for _, instruction := range instructions {
+		for _, denied := range policy.DeniedInstructions {
+			if strings.EqualFold(instruction.Cmd, denied) {
+				return deny("the instruction %s at line %d of the Dockerfile is not allowed", instruction.Cmd, instruction.Line)
+			}
+		}
+	}
+
+	baseImages, err := docker.ExtractBaseImages(dockerfilePath)
+	if err != nil {
+		return err
+	}
+	for _, image := range baseImages {
+		if err = admitImage(policy, image, "the base image"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// admitYaml checks the images of the services of the deploy.yaml of a space
+func admitYaml(yamlPath string) error {
+	if !conf.GetConfig().Admission.Enable {
+		return nil
+	}
+	containerResources, err := yaml.HandlerYaml(yamlPath)
+	if err != nil {
+		return err
+	}
+	return admitContainers(containerResources)
+}
+
+// admitContainers checks the images of the services of a deploy.yaml and of the services they depend on before they are deployed
+func admitContainers(containerResources []yaml.ContainerResource) error {
+	policy := conf.GetConfig().Admission
+	if !policy.Enable {
+		return nil
+	}
+
+	for _, cr := range containerResources {
+		for _, depend := range cr.Depends {
+			if err := admitImage(policy, depend.ImageName, "the image of the service "+depend.Name); err != nil {
+				return err
+			}
+		}
+		if err := admitImage(policy, cr.ImageName, "the image of the service "+cr.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// admitImage checks the registry, the name and the pinning of an image against the policy, subject names the image in the reason
+func admitImage(policy conf.Admission, image, subject string) error {
+	if strings.Contains(image, "$") {
+		return deny("%s %s uses a build argument without a default value", subject, image)
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return deny("%s %s can not be parsed: %v", subject, image, err)
+	}
+	domain := reference.Domain(named)
+	names := []string{named.Name(), reference.FamiliarName(named)}
+
+	if matchAny(policy.DeniedRegistries, domain) {
+		return deny("%s %s is from the denied registry %s", subject, image, domain)
+	}
+	if len(policy.AllowedRegistries) > 0 && !matchAny(policy.AllowedRegistries, domain) {
+		return deny("%s %s is from the registry %s, which is not allowed", subject, image, domain)
+	}
+	if matchAny(policy.DeniedImages, names...) {
+		return deny("%s %s is denied", subject, image)
+	}
+	if len(policy.AllowedImages) > 0 && !matchAny(policy.AllowedImages, names...) {
+		return deny("%s %s is not allowed", subject, image)
+	}
+	if _, pinned := named.(reference.Canonical); policy.RequireDigest && !pinned {
+		return deny("%s %s is not pinned by digest", subject, image)
+	}
+	return nil
+}
+
+// matchAny tells whether one of the values matches one of the glob patterns
+func matchAny(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value)); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
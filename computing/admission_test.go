@@ -0,0 +1,44 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+)
+
+func TestAdmitImage(t *testing.T) {
+	digest := "@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name    string
+		policy  conf.Admission
+		image   string
+		wantErr bool
+	}{
+		{name: "no policy", image: "python:3.11"},
+		{name: "unexpanded build argument", image: "python:${VERSION}", wantErr: true},
+		{name: "invalid reference", image: "Python:3.11", wantErr: true},
+		{name: "allowed registry", policy: conf.Admission{AllowedRegistries: []string{"docker.io", "ghcr.io"}},
+			image: "ghcr.io/lagrangedao/app:1.0"},
+		{name: "docker hub is docker.io", policy: conf.Admission{AllowedRegistries: []string{"docker.io"}}, image: "python:3.11"},
+		{name: "registry not allowed", policy: conf.Admission{AllowedRegistries: []string{"docker.io"}},
+			image: "quay.io/coreos/etcd:v3.5", wantErr: true},
+		{name: "denied registry", policy: conf.Admission{DeniedRegistries: []string{"*.example.com"}},
+			image: "registry.example.com/app:1.0", wantErr: true},
+		{name: "denied image by familiar name", policy: conf.Admission{DeniedImages: []string{"xmrig/*"}},
+			image: "xmrig/xmrig:latest", wantErr: true},
+		{name: "denied image by full name", policy: conf.Admission{DeniedImages: []string{"docker.io/library/busybox"}},
+			image: "busybox:1.36", wantErr: true},
+		{name: "allowed image", policy: conf.Admission{AllowedImages: []string{"python", "nvidia/*"}}, image: "nvidia/cuda:12.2.0-base-ubuntu22.04"},
+		{name: "image not allowed", policy: conf.Admission{AllowedImages: []string{"python"}}, image: "node:18", wantErr: true},
+		{name: "unpinned image", policy: conf.Admission{RequireDigest: true}, image: "python:3.11", wantErr: true},
+		{name: "pinned image", policy: conf.Admission{RequireDigest: true}, image: "python:3.11" + digest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := admitImage(tt.policy, tt.image, "the base image")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
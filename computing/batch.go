@@ -0,0 +1,263 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gomodule/redigo/redis"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultCollectorImage  = "busybox:1.36"
+	defaultBatchOutputPath = "/output"
+	batchCollectorName     = "collector"
+	batchCollectorPort     = 8080
+	batchOutputVolume      = "batch-output"
+	batchCollectVolume     = "batch-collect"
+	batchResultFile        = "output.tar.gz"
+)
+
+// batchPodSpec turns the pod of a service into the pod of a batch job: the service runs to completion as an init container,
+// then the collector packs its output directory and serves it until the provider downloaded it
+func batchPodSpec(podSpec coreV1.PodSpec, batch *yaml.Batch) coreV1.PodSpec {
+	outputPath := batch.OutputPath
+	if outputPath == "" {
+		outputPath = defaultBatchOutputPath
+	}
+	collectorImage := conf.GetConfig().Batch.CollectorImage
+	if collectorImage == "" {
+		collectorImage = defaultCollectorImage
+	}
+
+	main := podSpec.Containers[len(podSpec.Containers)-1]
+	main.ReadinessProbe, main.LivenessProbe, main.StartupProbe = nil, nil, nil
+	main.VolumeMounts = append(main.VolumeMounts, coreV1.VolumeMount{Name: batchOutputVolume, MountPath: outputPath})
+
+	collector := coreV1.Container{
+		Name:            batchCollectorName,
+		Image:           collectorImage,
+		ImagePullPolicy: coreV1.PullIfNotPresent,
+		Command: []string{"sh", "-c", fmt.Sprintf("tar czf /collect/%s -C %s . && exec httpd -f -p %d -h /collect",
+			batchResultFile, outputPath, batchCollectorPort)},
+		Ports: []coreV1.ContainerPort{{ContainerPort: batchCollectorPort, Protocol: coreV1.ProtocolTCP}},
+		ReadinessProbe: &coreV1.Probe{
+			ProbeHandler: coreV1.ProbeHandler{
+				TCPSocket: &coreV1.TCPSocketAction{Port: intstr.FromInt(batchCollectorPort)},
+			},
+			PeriodSeconds: 2,
+		},
+		Resources: coreV1.ResourceRequirements{
+			Limits: coreV1.ResourceList{
+				coreV1.ResourceCPU:    resource.MustParse("200m"),
+				coreV1.ResourceMemory: resource.MustParse("128Mi"),
+			},
+			Requests: coreV1.ResourceList{
+				coreV1.ResourceCPU:    resource.MustParse("50m"),
+				coreV1.ResourceMemory: resource.MustParse("32Mi"),
+			},
+		},
+		VolumeMounts: []coreV1.VolumeMount{
+			{Name: batchOutputVolume, MountPath: outputPath, ReadOnly: true},
+			{Name: batchCollectVolume, MountPath: "/collect"},
+		},
+	}
+
+	podSpec.InitContainers = []coreV1.Container{main}
+	podSpec.Containers = []coreV1.Container{collector}
+	podSpec.RestartPolicy = coreV1.RestartPolicyNever
+	podSpec.Volumes = append(podSpec.Volumes,
+		coreV1.Volume{Name: batchOutputVolume, VolumeSource: coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{}}},
+		coreV1.Volume{Name: batchCollectVolume, VolumeSource: coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{}}},
+	)
+	return podSpec
+}
+
+// runBatchJob runs the workload of the service as a Kubernetes Job to completion, its output is uploaded as the result
+// of the job and the hardware is freed before the lease ends
+func runBatchJob(jobUuid, creatorWallet, k8sNameSpace, spaceUuid string, workload spaceWorkload, cr yaml.ContainerResource,
+	template coreV1.PodTemplateSpec, duration int) error {
+	job := batchJob(creatorWallet, k8sNameSpace, spaceUuid, workload, cr, template, duration)
+	k8sService := NewK8sService()
+	if _, err := k8sService.CreateBatchJob(context.TODO(), k8sNameSpace, job); err != nil {
+		return fmt.Errorf("failed create batch job, error: %w", err)
+	}
+	updateJobStatus(jobUuid, models.JobPullImage)
+	logs.GetLogger().Infof("Created batch job: %s", job.Name)
+	watchContainerRunningTime(jobUuid, k8sNameSpace, spaceUuid, int64(duration))
+
+	if err := allowCollectorTraffic(k8sNameSpace, workload.Name); err != nil {
+		return fmt.Errorf("failed create networkPolicy of the collector, error: %w", err)
+	}
+
+	go func() {
+		if err := collectBatchResult(jobUuid, k8sNameSpace, spaceUuid, workload.Name, duration); err != nil {
+			logs.GetLogger().Errorf("Failed collect the result of batch job, jobUuid: %s, error: %v", jobUuid, err)
+			updateJobFailed(jobUuid, err.Error())
+		}
+	}()
+	return nil
+}
+
+// batchJob builds the Kubernetes Job running the workload of the service to completion within the duration of the lease
+func batchJob(creatorWallet, k8sNameSpace, spaceUuid string, workload spaceWorkload, cr yaml.ContainerResource,
+	template coreV1.PodTemplateSpec, duration int) *batchV1.Job {
+	template.Spec = batchPodSpec(template.Spec, cr.Batch)
+	hardenPodSpec(&template.Spec, creatorWallet, workload.Name, workload.Hardware)
+
+	backoffLimit := int32(cr.Batch.BackoffLimit)
+	activeDeadline := int64(duration)
+	return &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_JOB_NAME_PREFIX + workload.Name,
+			Namespace: k8sNameSpace,
+			Labels:    map[string]string{"lad_space_svc": spaceUuid},
+		},
+		Spec: batchV1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &activeDeadline,
+			Template:              template,
+		},
+	}
+}
+
+// collectBatchResult waits for the batch job of the workload to complete, uploads its output, then frees the hardware.
+// Only the batch job is deleted when the space runs other services, they keep running until the lease ends.
+func collectBatchResult(jobUuid, k8sNameSpace, spaceUuid, workloadName string, duration int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(duration)*time.Second)
+	defer cancel()
+	jobName := constants.K8S_JOB_NAME_PREFIX + workloadName
+	podName, err := NewK8sService().WaitForBatchOutput(ctx, k8sNameSpace, jobName, batchCollectorName)
+	if err != nil {
+		return err
+	}
+
+	resultCid, resultUrl, err := uploadBatchOutput(k8sNameSpace, podName, jobUuid)
+	if err != nil {
+		return err
+	}
+	saveJobRecord(jobUuid, map[string]string{"result_cid": resultCid, "result_url": resultUrl})
+	updateJobCompleted(jobUuid, resultUrl)
+	logs.GetLogger().Infof("Batch job completed, jobUuid: %s, result: %s", jobUuid, resultUrl)
+
+	if workloadName != spaceUuid {
+		deleteBatchJob(k8sNameSpace, workloadName)
+		return nil
+	}
+
+	// the lease key is dropped so its expiry does not clean up again, the job record is kept with the result until the lease ends
+	deleteJob(k8sNameSpace, spaceUuid)
+	releaseSpace(k8sNameSpace, spaceUuid)
+	conn := redisPool.Get()
+	defer conn.Close()
+	ttl, _ := redis.Int(conn.Do("TTL", jobUuid))
+	if _, err = conn.Do("DEL", jobUuid); err != nil {
+		logs.GetLogger().Errorf("Failed delete redis key, key: %s, error: %+v", jobUuid, err)
+	}
+	if ttl > 0 {
+		conn.Do("EXPIRE", constants.REDIS_FULL_PREFIX+jobUuid, ttl)
+	}
+	return nil
+}
+
+// uploadBatchOutput downloads the output served by the collector of the pod and uploads it to the bucket
+func uploadBatchOutput(k8sNameSpace, podName, jobUuid string) (string, string, error) {
+	stream, err := NewK8sService().GetPodFile(context.TODO(), k8sNameSpace, podName, batchCollectorPort, "/"+batchResultFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed download the output of batch job, error: %w", err)
+	}
+	defer stream.Close()
+
+	folderPath := "results"
+	resultFile := filepath.Join(folderPath, jobUuid+".tar.gz")
+	resultFilePath := filepath.Join(conf.GetConfig().MCS.FileCachePath, resultFile)
+	os.MkdirAll(filepath.Dir(resultFilePath), os.ModePerm)
+	file, err := os.Create(resultFilePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed create result file, error: %w", err)
+	}
+	defer os.Remove(resultFilePath)
+	_, err = io.Copy(file, stream)
+	file.Close()
+	if err != nil {
+		return "", "", fmt.Errorf("failed download the output of batch job, error: %w", err)
+	}
+
+	storageService := NewStorageService()
+	if storageService == nil {
+		return "", "", fmt.Errorf("failed upload the output of batch job, the mcs client is unavailable")
+	}
+	mcsOssFile, err := storageService.UploadFileToBucket(resultFile, resultFilePath, true)
+	if err != nil {
+		return "", "", fmt.Errorf("failed upload the output of batch job, error: %w", err)
+	}
+	gatewayUrl, err := storageService.GetGatewayUrl()
+	if err != nil {
+		return "", "", fmt.Errorf("failed get mcs ipfs gatewayUrl, error: %w", err)
+	}
+	return mcsOssFile.PayloadCid, *gatewayUrl + "/ipfs/" + mcsOssFile.PayloadCid, nil
+}
+
+// allowCollectorTraffic lets the API server proxy reach the collector of the batch job of the workload
+func allowCollectorTraffic(k8sNameSpace, workloadName string) error {
+	if !conf.GetConfig().NetworkPolicy.Enable {
+		return nil
+	}
+
+	k8sService := NewK8sService()
+	clusterApiIps, err := k8sService.GetClusterApiIps(context.TODO())
+	if err != nil {
+		return err
+	}
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, ip := range clusterApiIps {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: hostCidr(ip)}})
+	}
+	networkPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_JOB_NAME_PREFIX + workloadName,
+			Namespace: k8sNameSpace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metaV1.LabelSelector{
+				MatchLabels: map[string]string{"lad_app": workloadName},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From:  peers,
+					Ports: []networkingv1.NetworkPolicyPort{networkPolicyPort(coreV1.ProtocolTCP, batchCollectorPort)},
+				},
+			},
+		},
+	}
+	_, err = k8sService.CreateNetworkPolicy(context.TODO(), k8sNameSpace, networkPolicy)
+	return err
+}
+
+// deleteBatchJob deletes the batch job of the workload and the network policy of its collector
+func deleteBatchJob(k8sNameSpace, workloadName string) {
+	jobName := constants.K8S_JOB_NAME_PREFIX + workloadName
+	k8sService := NewK8sService()
+	if err := k8sService.DeleteBatchJob(context.TODO(), k8sNameSpace, jobName); err != nil && !errors.IsNotFound(err) {
+		logs.GetLogger().Errorf("Failed delete batch job, jobName: %s, error: %+v", jobName, err)
+	}
+	if err := k8sService.DeleteNetworkPolicy(context.TODO(), k8sNameSpace, jobName); err != nil && !errors.IsNotFound(err) {
+		logs.GetLogger().Errorf("Failed delete networkPolicy, name: %s, error: %+v", jobName, err)
+	}
+}
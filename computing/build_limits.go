@@ -0,0 +1,113 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	defaultBuildTimeout     = time.Hour
+	defaultBuildCpu         = "2"
+	defaultBuildMemory      = "4Gi"
+	defaultBuildContextSize = "1Gi"
+	defaultKeepImages       = 3
+	fileDownloadTimeout     = 10 * time.Minute
+)
+
+// buildTimeout returns how long the build of an image may run
+func buildTimeout() time.Duration {
+	if minutes := conf.GetConfig().Build.TimeoutMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultBuildTimeout
+}
+
+// buildResources returns the CPU and memory the steps of a build can use
+func buildResources() (cpu, memory resource.Quantity, err error) {
+	buildConf := conf.GetConfig().Build
+	cpu, err = resource.ParseQuantity(valueOrDefault(buildConf.Cpu, defaultBuildCpu))
+	if err != nil {
+		return cpu, memory, fmt.Errorf("invalid Build.Cpu %s, error: %w", buildConf.Cpu, err)
+	}
+	memory, err = resource.ParseQuantity(valueOrDefault(buildConf.Memory, defaultBuildMemory))
+	if err != nil {
+		return cpu, memory, fmt.Errorf("invalid Build.Memory %s, error: %w", buildConf.Memory, err)
+	}
+	return cpu, memory, nil
+}
+
+// dockerBuildLimits returns the limits of the builds run by the Docker daemon of the host
+func dockerBuildLimits() (docker.BuildLimits, error) {
+	cpu, memory, err := buildResources()
+	if err != nil {
+		return docker.BuildLimits{}, err
+	}
+	networkMode := strings.ToLower(conf.GetConfig().Build.NetworkMode)
+	switch networkMode {
+	case "", "default", "none":
+	default:
+		return docker.BuildLimits{}, fmt.Errorf("invalid Build.NetworkMode %s, it must be default or none", networkMode)
+	}
+	return docker.BuildLimits{
+		NanoCpus:    cpu.MilliValue() * 1e6,
+		Memory:      memory.Value(),
+		NetworkMode: networkMode,
+	}, nil
+}
+
+// buildContextLimit returns the size the files of a space can reach, Build.MaxContextSize
+func buildContextLimit() (resource.Quantity, error) {
+	maxSize, err := resource.ParseQuantity(valueOrDefault(conf.GetConfig().Build.MaxContextSize, defaultBuildContextSize))
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("invalid Build.MaxContextSize %s, error: %w", conf.GetConfig().Build.MaxContextSize, err)
+	}
+	return maxSize, nil
+}
+
+// checkBuildContextSize fails when the files of the build context are larger than Build.MaxContextSize
+func checkBuildContextSize(imagePath string) error {
+	maxSize, err := buildContextLimit()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	err = filepath.Walk(imagePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed measure the build context, error: %w", err)
+	}
+	if size > maxSize.Value() {
+		return fmt.Errorf("the build context is %s, larger than the limit of %s",
+			resource.NewQuantity(size, resource.BinarySI).String(), maxSize.String())
+	}
+	return nil
+}
+
+// buildFailureReason explains the failures caused by the limits of the build run with ctx
+func buildFailureReason(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), strings.Contains(err.Error(), "DeadlineExceeded"):
+		return fmt.Errorf("the build did not complete in %s, error: %w", buildTimeout(), err)
+	case strings.Contains(err.Error(), "code: 137"), strings.Contains(err.Error(), "OOMKilled"):
+		_, memory, _ := buildResources()
+		return fmt.Errorf("a build step was killed, it may use more than the memory limit of %s, error: %w", memory.String(), err)
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package computing
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+	"github.com/lagrangedao/go-computing-provider/common"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	defaultBuildLogLimit     = "256Ki"
+	defaultBuildLogRetain    = 72 * time.Hour
+	buildLogTruncatedMessage = "... the beginning of the build output is truncated ...\n"
+)
+
+// buildLog captures the output of the build and the push of the image of a job, it keeps the last bytes written
+// beyond its limit since the reason of a failure is at the end
+type buildLog struct {
+	lock      sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func newBuildLog() *buildLog {
+	limit, err := resource.ParseQuantity(valueOrDefault(conf.GetConfig().Build.LogLimit, defaultBuildLogLimit))
+	if err != nil || limit.Value() <= 0 {
+		logs.GetLogger().Warnf("Invalid Build.LogLimit %s, use %s", conf.GetConfig().Build.LogLimit, defaultBuildLogLimit)
+		limit = resource.MustParse(defaultBuildLogLimit)
+	}
+	return &buildLog{limit: int(limit.Value())}
+}
+
+func (l *buildLog) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.buf = append(l.buf, p...)
+	if overflow := len(l.buf) - l.limit; overflow > 0 {
+		l.buf = append(l.buf[:0], l.buf[overflow:]...)
+		l.truncated = true
+	}
+	return len(p), nil
+}
+
+func (l *buildLog) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.truncated {
+		return buildLogTruncatedMessage + string(l.buf)
+	}
+	return string(l.buf)
+}
+
+// saveBuildLog stores the build output of the job for Build.LogRetainHours, the output of a failed build is also
+// uploaded to the MCS bucket when Build.UploadFailedLog is set, its url is returned
+func saveBuildLog(jobUuid string, buildLog *buildLog, buildErr error) string {
+	content := buildLog.String()
+	fields := map[string]string{
+		"log":       content,
+		"truncated": strconv.FormatBool(buildLog.truncated),
+		"failed":    strconv.FormatBool(buildErr != nil),
+	}
+	if buildErr != nil && conf.GetConfig().Build.UploadFailedLog {
+		logUrl, err := uploadBuildLog(jobUuid, content)
+		if err != nil {
+			logs.GetLogger().Errorf("Failed upload build log, jobUuid: %s, error: %+v", jobUuid, err)
+		}
+		fields["log_url"] = logUrl
+	}
+
+	retain := defaultBuildLogRetain
+	if hours := conf.GetConfig().Build.LogRetainHours; hours > 0 {
+		retain = time.Duration(hours) * time.Hour
+	}
+
+	conn := redisPool.Get()
+	defer conn.Close()
+	key := constants.REDIS_BUILD_LOG_PREFIX + jobUuid
+	args := redis.Args{}.Add(key)
+	for field, value := range fields {
+		args = args.Add(field, value)
+	}
+	conn.Send("MULTI")
+	conn.Send("DEL", key)
+	conn.Send("HSET", args...)
+	conn.Send("EXPIRE", key, int(retain/time.Second))
+	if _, err := conn.Do("EXEC"); err != nil {
+		logs.GetLogger().Errorf("Failed save build log, jobUuid: %s, error: %+v", jobUuid, err)
+	}
+	return fields["log_url"]
+}
+
+func uploadBuildLog(jobUuid, content string) (string, error) {
+	logFile := filepath.Join("build-logs", jobUuid+".log")
+	logFilePath := filepath.Join(conf.GetConfig().MCS.FileCachePath, logFile)
+	os.MkdirAll(filepath.Dir(logFilePath), os.ModePerm)
+	if err := os.WriteFile(logFilePath, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	defer os.Remove(logFilePath)
+
+	storageService := NewStorageService()
+	if storageService == nil {
+		return "", fmt.Errorf("the mcs client is unavailable")
+	}
+	mcsOssFile, err := storageService.UploadFileToBucket(logFile, logFilePath, true)
+	if err != nil {
+		return "", err
+	}
+	gatewayUrl, err := storageService.GetGatewayUrl()
+	if err != nil {
+		return "", fmt.Errorf("failed get mcs ipfs gatewayUrl, error: %w", err)
+	}
+	return *gatewayUrl + "/ipfs/" + mcsOssFile.PayloadCid, nil
+}
+
+func getBuildLog(jobUuid string) (*models.BuildLog, error) {
+	if jobUuid == "" {
+		return nil, fmt.Errorf("job_uuid is required")
+	}
+
+	conn := redisPool.Get()
+	defer conn.Close()
+	values, err := redis.StringMap(conn.Do("HGETALL", constants.REDIS_BUILD_LOG_PREFIX+jobUuid))
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get build log, jobUuid: %s, error: %+v", jobUuid, err)
+		return nil, fmt.Errorf("failed get build log, error: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("build log not found")
+	}
+
+	buildLog := &models.BuildLog{
+		JobUuid: jobUuid,
+		Log:     values["log"],
+		LogUrl:  values["log_url"],
+	}
+	buildLog.Truncated, _ = strconv.ParseBool(values["truncated"])
+	buildLog.Failed, _ = strconv.ParseBool(values["failed"])
+	return buildLog, nil
+}
+
+// GetJobBuildLog returns the output of the image build and push of a job deployed from a Dockerfile
+func GetJobBuildLog(c *gin.Context) {
+	buildLog, err := getBuildLog(c.Query("job_uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.CreateSuccessResponse(buildLog))
+}
@@ -0,0 +1,41 @@
+package computing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildLogWrite(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int
+		writes        []string
+		want          string
+		wantTruncated bool
+	}{
+		{name: "within the limit", limit: 16, writes: []string{"step 1\n", "step 2\n"}, want: "step 1\nstep 2\n"},
+		{name: "at the limit", limit: 14, writes: []string{"step 1\n", "step 2\n"}, want: "step 1\nstep 2\n"},
+		{name: "keeps the end of the output", limit: 10, writes: []string{"step 1\n", "step 2\n"},
+			want: buildLogTruncatedMessage + " 1\nstep 2\n", wantTruncated: true},
+		{name: "a single write beyond the limit", limit: 4, writes: []string{"error: exit 1"},
+			want: buildLogTruncatedMessage + "it 1", wantTruncated: true},
+		{name: "stays truncated", limit: 8, writes: []string{strings.Repeat("a", 9), "b"},
+			want: buildLogTruncatedMessage + "aaaaaaab", wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &buildLog{limit: tt.limit}
+			for _, p := range tt.writes {
+				if n, err := l.Write([]byte(p)); err != nil || n != len(p) {
+					t.Fatalf("got %d, %v, want %d, nil", n, err, len(p))
+				}
+			}
+			if got := l.String(); got != tt.want || l.truncated != tt.wantTruncated {
+				t.Fatalf("got %q truncated %v, want %q truncated %v", got, l.truncated, tt.want, tt.wantTruncated)
+			}
+			if len(l.buf) > tt.limit {
+				t.Fatalf("kept %d bytes, more than the limit of %d", len(l.buf), tt.limit)
+			}
+		})
+	}
+}
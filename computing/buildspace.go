@@ -1,6 +1,9 @@
 package computing
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
@@ -15,7 +18,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 var NotFoundError = errors.New("not found resource")
@@ -39,21 +41,37 @@ func getSpaceName(apiURL string) (string, string, error) {
 }
 
 func BuildSpaceTaskImage(spaceUuid string, files []models.SpaceFile) (bool, string, string, error) {
+	return downloadSpaceFiles("build/", spaceUuid, files)
+}
+
+// downloadSpaceFiles downloads the files of the space into buildFolder, and returns whether the space is deployed
+// from a deploy.yaml, the path of the deploy.yaml and the folder of the space
+func downloadSpaceFiles(buildFolder, spaceUuid string, files []models.SpaceFile) (bool, string, string, error) {
 	var err error
-	buildFolder := "build/"
 	if len(files) > 0 {
+		// files removed from the space must not stay in the build context
+		imagePath := filepath.Join(buildFolder, getDownloadPath(files[0].Name))
+		if err = os.RemoveAll(imagePath); err != nil {
+			return false, "", "", err
+		}
+		maxSize, err := buildContextLimit()
+		if err != nil {
+			return false, "", "", err
+		}
+		remaining := maxSize.Value()
 		for _, file := range files {
 			dirPath := filepath.Dir(file.Name)
 			if err = os.MkdirAll(filepath.Join(buildFolder, dirPath), os.ModePerm); err != nil {
 				return false, "", "", err
 			}
-			if err = downloadFile(filepath.Join(buildFolder, file.Name), file.URL); err != nil {
+			written, err := downloadFile(filepath.Join(buildFolder, file.Name), file.URL, remaining)
+			if err != nil {
 				return false, "", "", fmt.Errorf("error downloading file: %w", err)
 			}
+			remaining -= written
 			logs.GetLogger().Infof("Download %s successfully.", spaceUuid)
 		}
 
-		imagePath := filepath.Join(buildFolder, getDownloadPath(files[0].Name))
 		var containsYaml bool
 		var yamlPath string
 
@@ -80,38 +98,157 @@ func getDownloadPath(fileName string) string {
 	return filepath.Join(splits[0], splits[1], splits[2])
 }
 
-func BuildImagesByDockerfile(jobUuid, spaceUuid, spaceName, imagePath string) (string, string) {
+// BuildImagesByDockerfile builds the image of the space with the configured builder and pushes it to the registry when there is one.
+// The image is tagged with the hash of the build context, an image built before from the same files is reused.
+// The build and push output is kept as the build log of the job.
+func BuildImagesByDockerfile(jobUuid, spaceUuid, spaceName, imagePath string) (imageName string, dockerfilePath string, err error) {
 	updateJobStatus(jobUuid, models.JobBuildImage)
-	spaceFlag := spaceName + spaceUuid[:strings.LastIndex(spaceUuid, "-")]
-	imageName := fmt.Sprintf("lagrange/%s:%d", spaceFlag, time.Now().Unix())
-	if conf.GetConfig().Registry.ServerAddress != "" {
-		imageName = fmt.Sprintf("%s/%s:%d",
-			strings.TrimSpace(conf.GetConfig().Registry.ServerAddress), spaceFlag, time.Now().Unix())
+	imageName, err = spaceImageName(spaceUuid, spaceName, imagePath)
+	if err != nil {
+		return "", "", err
 	}
-	imageName = strings.ToLower(imageName)
-	dockerfilePath := filepath.Join(imagePath, "Dockerfile")
+	dockerfilePath = filepath.Join(imagePath, "Dockerfile")
 	log.Printf("Image path: %s", imagePath)
 
+	buildLog := newBuildLog()
+	defer func() {
+		if logUrl := saveBuildLog(jobUuid, buildLog, err); logUrl != "" {
+			err = fmt.Errorf("%w, build log: %s", err, logUrl)
+		}
+	}()
+
+	hasRegistry := conf.GetConfig().Registry.ServerAddress != ""
+	if hasRegistry {
+		exists, err := docker.RegistryHasImage(imageName)
+		if err != nil {
+			logs.GetLogger().Warnf("Failed check the image in the registry, image: %s, error: %v", imageName, err)
+		}
+		if exists {
+			logs.GetLogger().Infof("Reuse the image %s of unchanged space files", imageName)
+			fmt.Fprintf(buildLog, "Reuse the image %s of unchanged space files\n", imageName)
+			return imageName, dockerfilePath, nil
+		}
+	}
+
+	if err := checkBuildContextSize(imagePath); err != nil {
+		return "", "", err
+	}
+
+	if isKanikoBuilder() {
+		if err := buildImageInCluster(jobUuid, imagePath, imageName, buildLog); err != nil {
+			return "", "", err
+		}
+		return imageName, dockerfilePath, nil
+	}
+
+	limits, err := dockerBuildLimits()
+	if err != nil {
+		return "", "", err
+	}
 	dockerService := docker.NewDockerService()
-	if err := dockerService.BuildImage(imagePath, imageName); err != nil {
-		logs.GetLogger().Errorf("Error building Docker image: %v", err)
-		return "", ""
+	exists, err := dockerService.ImageExists(context.TODO(), imageName)
+	if err != nil {
+		logs.GetLogger().Warnf("Failed check the local image, image: %s, error: %v", imageName, err)
+	}
+	if exists {
+		logs.GetLogger().Infof("Reuse the local image %s of unchanged space files", imageName)
+		fmt.Fprintf(buildLog, "Reuse the local image %s of unchanged space files\n", imageName)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), buildTimeout())
+		defer cancel()
+		if err := dockerService.BuildImage(ctx, imagePath, imageName, limits, buildLog); err != nil {
+			logs.GetLogger().Errorf("Error building Docker image: %v", err)
+			return "", "", fmt.Errorf("failed build image, %w", buildFailureReason(ctx, err))
+		}
 	}
 
-	if conf.GetConfig().Registry.ServerAddress != "" {
+	if hasRegistry {
 		updateJobStatus(jobUuid, models.JobPushImage)
-		if err := dockerService.PushImage(imageName); err != nil {
+		if err := dockerService.PushImage(imageName, buildLog); err != nil {
 			logs.GetLogger().Errorf("Error Docker push image: %v", err)
-			return "", ""
+			return "", "", fmt.Errorf("failed push image, error: %w", err)
 		}
 	}
-	return imageName, dockerfilePath
+	pruneSpaceImages(dockerService, imageName)
+	return imageName, dockerfilePath, nil
+}
+
+// pruneSpaceImages keeps the Build.KeepImages most recent images of the space on the host, the image just built
+// included. The images pushed to the Registry are left to the retention policy of the registry.
+func pruneSpaceImages(dockerService *docker.DockerService, imageName string) {
+	keep := conf.GetConfig().Build.KeepImages
+	if keep <= 0 {
+		keep = defaultKeepImages
+	}
+	repository := imageName[:strings.LastIndex(imageName, ":")]
+	if err := dockerService.PruneRepositoryImages(context.TODO(), repository, keep); err != nil {
+		logs.GetLogger().Warnf("Failed prune the images of %s, error: %v", repository, err)
+	}
+}
+
+// spaceImageName returns the name the image of the space is built with, in the registry of the provider when configured,
+// tagged with the hash of its build context
+func spaceImageName(spaceUuid, spaceName, imagePath string) (string, error) {
+	contextHash, err := buildContextHash(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("failed hash the build context, error: %w", err)
+	}
+	spaceFlag := spaceName + spaceUuid[:strings.LastIndex(spaceUuid, "-")]
+	imageName := fmt.Sprintf("lagrange/%s:%s", spaceFlag, contextHash[:16])
+	if conf.GetConfig().Registry.ServerAddress != "" {
+		imageName = fmt.Sprintf("%s/%s:%s",
+			strings.TrimSpace(conf.GetConfig().Registry.ServerAddress), spaceFlag, contextHash[:16])
+	}
+	return strings.ToLower(imageName), nil
 }
 
-func downloadFile(filepath string, url string) error {
+// buildContextHash returns the sha256 of the paths, modes and contents of the files of the build context, the Dockerfile
+// included, so that the same space files always map to the same image
+func buildContextHash(imagePath string) (string, error) {
+	hash := sha256.New()
+	err := filepath.Walk(imagePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(imagePath, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%o\x00", filepath.ToSlash(relPath), info.Mode())
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%s\x00", target)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err = io.Copy(hash, file); err != nil {
+			return err
+		}
+		hash.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// downloadFile writes the file at url to filepath and returns its size, it fails when the file is larger than
+// maxSize or is not downloaded within fileDownloadTimeout
+func downloadFile(filepath string, url string, maxSize int64) (int64, error) {
 	out, err := os.Create(filepath)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func(out *os.File) {
 		err := out.Close()
@@ -120,9 +257,10 @@ func downloadFile(filepath string, url string) error {
 		}
 	}(out)
 
-	resp, err := http.Get(url)
+	client := &http.Client{Timeout: fileDownloadTimeout}
+	resp, err := client.Get(url)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -132,13 +270,18 @@ func downloadFile(filepath string, url string) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("url: %s, unexpected status code: %d", url, resp.StatusCode)
+		return 0, fmt.Errorf("url: %s, unexpected status code: %d", url, resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return 0, fmt.Errorf("url: %s, the files of the space are larger than the limit of the build context", url)
 	}
 
-	_, err = io.Copy(out, resp.Body)
+	written, err := io.Copy(out, io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
-		return err
+		return written, err
 	}
-
-	return nil
+	if written > maxSize {
+		return written, fmt.Errorf("url: %s, the files of the space are larger than the limit of the build context", url)
+	}
+	return written, nil
 }
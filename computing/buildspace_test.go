@@ -0,0 +1,86 @@
+package computing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 16)))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		wantErr bool
+	}{
+		{name: "within the limit", maxSize: 32},
+		{name: "at the limit", maxSize: 16},
+		{name: "beyond the limit", maxSize: 15, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written, err := downloadFile(filepath.Join(t.TempDir(), "Dockerfile"), server.URL, tt.maxSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && written != 16 {
+				t.Fatalf("got %d bytes, want 16", written)
+			}
+		})
+	}
+}
+
+func TestBuildContextHash(t *testing.T) {
+	writeContext := func(t *testing.T, files map[string]string, mode os.FileMode) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	base := map[string]string{"Dockerfile": "FROM python:3.11\n", "app/main.py": "print(1)\n"}
+	want, err := buildContextHash(writeContext(t, base, 0644))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		mode     os.FileMode
+		wantSame bool
+	}{
+		{name: "same files in another folder", files: base, mode: 0644, wantSame: true},
+		{name: "changed content", files: map[string]string{"Dockerfile": "FROM python:3.12\n", "app/main.py": "print(1)\n"}, mode: 0644},
+		{name: "renamed file", files: map[string]string{"Dockerfile": "FROM python:3.11\n", "app/app.py": "print(1)\n"}, mode: 0644},
+		{name: "content moved between files", files: map[string]string{"Dockerfile": "FROM python:3.11\nprint(1)\n", "app/main.py": ""}, mode: 0644},
+		{name: "changed mode", files: base, mode: 0755},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildContextHash(writeContext(t, tt.files, tt.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == want) != tt.wantSame {
+				t.Fatalf("got hash %s, base hash %s, want same %v", got, want, tt.wantSame)
+			}
+		})
+	}
+}
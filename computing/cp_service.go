@@ -4,11 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/lagrangedao/go-computing-provider/docker"
 	"github.com/lagrangedao/go-computing-provider/yaml"
 	"io"
 	appV1 "k8s.io/api/apps/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	"math/rand"
 	"net/http"
 	"os"
@@ -50,14 +48,7 @@ func ReceiveJob(c *gin.Context) {
 	}
 	logs.GetLogger().Infof("Job received Data: %+v", jobData)
 
-	var hostName string
-	prefixStr := generateString(10)
-	if strings.HasPrefix(conf.GetConfig().API.Domain, ".") {
-		hostName = prefixStr + conf.GetConfig().API.Domain
-	} else {
-		hostName = strings.Join([]string{prefixStr, conf.GetConfig().API.Domain}, ".")
-	}
-
+	hostName := newHostName()
 	delayTask, err := celeryService.DelayTask(constants.TASK_DEPLOY, jobData.JobSourceURI, hostName, jobData.Duration, jobData.UUID)
 	if err != nil {
 		logs.GetLogger().Errorf("Failed sync delpoy task, error: %v", err)
@@ -78,6 +69,15 @@ func ReceiveJob(c *gin.Context) {
 	c.JSON(http.StatusOK, jobData)
 }
 
+// newHostName returns a random host name under the domain of the provider
+func newHostName() string {
+	prefixStr := generateString(10)
+	if strings.HasPrefix(conf.GetConfig().API.Domain, ".") {
+		return prefixStr + conf.GetConfig().API.Domain
+	}
+	return strings.Join([]string{prefixStr, conf.GetConfig().API.Domain}, ".")
+}
+
 func submitJob(jobData *models.JobData) {
 	logs.GetLogger().Printf("submitting job...")
 	oldMask := syscall.Umask(0)
@@ -261,10 +261,58 @@ func DeleteJob(c *gin.Context) {
 	}
 
 	k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(creatorWallet)
-	deleteJob(k8sNameSpace, spaceUuid)
+	getOrchestrator().Delete(k8sNameSpace, spaceUuid)
 	c.JSON(http.StatusOK, common.CreateSuccessResponse("deleted success"))
 }
 
+func GetJob(c *gin.Context) {
+	jobUuid := c.Query("job_uuid")
+	if jobUuid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job_uuid is required"})
+		return
+	}
+
+	jobRecord, err := getJobRecord(jobUuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.CreateSuccessResponse(jobRecord))
+}
+
+func getJobRecord(jobUuid string) (*models.JobRecord, error) {
+	if jobUuid == "" {
+		return nil, fmt.Errorf("job_uuid is required")
+	}
+
+	conn := redisPool.Get()
+	defer conn.Close()
+	values, err := redis.StringMap(conn.Do("HGETALL", constants.REDIS_FULL_PREFIX+jobUuid))
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get job record, jobUuid: %s, error: %+v", jobUuid, err)
+		return nil, fmt.Errorf("failed get job record, error: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("job not found")
+	}
+
+	jobRecord := &models.JobRecord{
+		JobUuid:   jobUuid,
+		SpaceUuid: values["space_uuid"],
+		Namespace: values["k8s_namespace"],
+		HostName:  values["host_name"],
+		ResultCid: values["result_cid"],
+		ResultUrl: values["result_url"],
+	}
+	jobRecord.ExpireTime, _ = strconv.ParseInt(values["expire_time"], 10, 64)
+	if ports := values["ports"]; ports != "" {
+		if err = json.Unmarshal([]byte(ports), &jobRecord.Ports); err != nil {
+			logs.GetLogger().Errorf("Failed parse job ports, jobUuid: %s, error: %+v", jobUuid, err)
+		}
+	}
+	return jobRecord, nil
+}
+
 func StatisticalSources(c *gin.Context) {
 	location, err := getLocation()
 	if err != nil {
@@ -272,8 +320,7 @@ func StatisticalSources(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed get location info"})
 	}
 
-	k8sService := NewK8sService()
-	statisticalSources, err := k8sService.StatisticalSources(context.TODO())
+	statisticalSources, err := getOrchestrator().NodeResources(context.TODO())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
@@ -293,38 +340,10 @@ func DeploySpaceTask(jobSourceURI, hostName string, duration int, jobUuid string
 			return
 		}
 	}()
-	var gpuName string
-	defer func() {
-		if gpuName != "" {
-			count, ok := runTaskGpuResource.Load(gpuName)
-			if ok && count.(int) > 0 {
-				runTaskGpuResource.Store(gpuName, count.(int)-1)
-			} else {
-				runTaskGpuResource.Delete(gpuName)
-			}
-		}
-	}()
 
-	resp, err := http.Get(jobSourceURI)
+	spaceJson, err := fetchSpace(jobSourceURI)
 	if err != nil {
-		logs.GetLogger().Errorf("error making request to Space API: %+v", err)
-		return ""
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			logs.GetLogger().Errorf("error closed resp Space API: %+v", err)
-		}
-	}(resp.Body)
-	logs.GetLogger().Infof("Space API response received. Response: %d", resp.StatusCode)
-	if resp.StatusCode != http.StatusOK {
-		logs.GetLogger().Errorf("space API response not OK. Status Code: %d", resp.StatusCode)
-		return ""
-	}
-
-	var spaceJson models.SpaceJSON
-	if err := json.NewDecoder(resp.Body).Decode(&spaceJson); err != nil {
-		logs.GetLogger().Errorf("error decoding Space API response JSON: %v", err)
+		logs.GetLogger().Error(err)
 		return ""
 	}
 
@@ -339,328 +358,274 @@ func DeploySpaceTask(jobSourceURI, hostName string, duration int, jobUuid string
 	}
 	hardwareInfo := getHardwareDetail(spaceHardware.Description)
 
-	if hardwareInfo.Gpu.Unit != "" {
-		gpuName = strings.ReplaceAll(hardwareInfo.Gpu.Unit, " ", "-")
-		count, ok := runTaskGpuResource.Load(gpuName)
-		if ok {
-			runTaskGpuResource.Store(gpuName, count.(int)+1)
-		} else {
-			runTaskGpuResource.Store(gpuName, 1)
-		}
-	}
+	releaseGpu := reserveTaskGpu(gpuTaskKey(hardwareInfo), int(hardwareInfo.Gpu.Quantity))
+	defer releaseGpu()
 
 	updateJobStatus(jobUuid, models.JobDownloadSource)
 	containsYaml, yamlPath, imagePath, err := BuildSpaceTaskImage(spaceUuid, spaceJson.Data.Files)
 	if err != nil {
 		logs.GetLogger().Error(err)
+		updateJobFailed(jobUuid, err.Error())
 		return ""
 	}
 
 	if containsYaml {
-		yamlToK8s(jobUuid, creator, spaceUuid, yamlPath, hostName, hardwareInfo, duration)
+		err = admitYaml(yamlPath)
+		if err == nil {
+			err = getOrchestrator().DeployYaml(jobUuid, creator, spaceUuid, yamlPath, hostName, hardwareInfo, duration)
+		}
 	} else {
-		imageName, dockerfilePath := BuildImagesByDockerfile(jobUuid, spaceUuid, spaceName, imagePath)
-		dockerfileToK8s(jobUuid, hostName, creator, spaceUuid, imageName, dockerfilePath, hardwareInfo, duration)
+		var imageName, dockerfilePath string
+		err = admitDockerfile(filepath.Join(imagePath, "Dockerfile"))
+		if err == nil {
+			imageName, dockerfilePath, err = BuildImagesByDockerfile(jobUuid, spaceUuid, spaceName, imagePath)
+		}
+		if err == nil {
+			err = getOrchestrator().DeployDockerfile(jobUuid, hostName, creator, spaceUuid, imageName, dockerfilePath, hardwareInfo, duration)
+		}
+	}
+	if err != nil {
+		logs.GetLogger().Errorf("Failed deploy space, jobUuid: %s, spaceUuid: %s, error: %v", jobUuid, spaceUuid, err)
+		updateJobFailed(jobUuid, err.Error())
+		return ""
 	}
 	return hostName
 }
 
-func dockerfileToK8s(jobUuid, hostName, creatorWallet, spaceUuid, imageName, dockerfilePath string, hardwareResource models.Resource, duration int) {
-	exposedPort, err := docker.ExtractExposedPort(dockerfilePath)
+// fetchSpace gets the files, the owner and the hardware of the space from the space API
+func fetchSpace(jobSourceURI string) (*models.SpaceJSON, error) {
+	resp, err := http.Get(jobSourceURI)
 	if err != nil {
-		logs.GetLogger().Infof("Failed to extract exposed port: %v", err)
-		return
+		return nil, fmt.Errorf("error making request to Space API: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logs.GetLogger().Errorf("error closed resp Space API: %+v", err)
+		}
+	}(resp.Body)
+	logs.GetLogger().Infof("Space API response received. Response: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("space API response not OK. Status Code: %d", resp.StatusCode)
+	}
+
+	var spaceJson models.SpaceJSON
+	if err := json.NewDecoder(resp.Body).Decode(&spaceJson); err != nil {
+		return nil, fmt.Errorf("error decoding Space API response JSON: %w", err)
 	}
-	containerPort, err := strconv.ParseInt(exposedPort, 10, 64)
+	return &spaceJson, nil
+}
+
+func dockerfileToK8s(jobUuid, hostName, creatorWallet, spaceUuid, imageName, dockerfilePath string, hardwareResource models.Resource, duration int) (err error) {
+	cr, err := dockerfileResource(imageName, dockerfilePath)
 	if err != nil {
-		logs.GetLogger().Errorf("Failed to convert exposed port: %v", err)
-		return
+		return err
 	}
 
 	// first delete old resource
 	k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + creatorWallet
 	deleteJob(k8sNameSpace, spaceUuid)
+	defer func() {
+		if err != nil {
+			teardownFailedSpace(k8sNameSpace, spaceUuid)
+		}
+	}()
 
 	if err := deployNamespace(creatorWallet); err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
-	memQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardwareResource.Memory.Quantity, hardwareResource.Memory.Unit))
+	var volumes []coreV1.Volume
+	var volumeMounts []coreV1.VolumeMount
+	deployStrategy := appV1.DeploymentStrategy{}
+	volume, persistentMounts, err := spacePersistentVolume(k8sNameSpace, spaceUuid, cr.PersistentStorage)
 	if err != nil {
-		logs.GetLogger().Errorf("get memory failed, error: %+v", err)
-		return
+		return err
+	}
+	if volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, persistentMounts...)
+		deployStrategy.Type = appV1.RecreateDeploymentStrategyType
 	}
 
-	storageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardwareResource.Storage.Quantity, hardwareResource.Storage.Unit))
+	template, err := dockerfilePodTemplate(jobUuid, hostName, creatorWallet, k8sNameSpace, spaceUuid, cr, hardwareResource, volumes, volumeMounts)
 	if err != nil {
-		logs.GetLogger().Errorf("get storage failed, error: %+v", err)
-		return
+		return err
 	}
 
 	// create deployment
 	k8sService := NewK8sService()
-	deployment := &appV1.Deployment{
-		TypeMeta: metaV1.TypeMeta{
-			Kind:       "Deployment",
-			APIVersion: "apps/v1",
-		},
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:      constants.K8S_DEPLOY_NAME_PREFIX + spaceUuid,
-			Namespace: k8sNameSpace,
-		},
-		Spec: appV1.DeploymentSpec{
-			Selector: &metaV1.LabelSelector{
-				MatchLabels: map[string]string{"lad_app": spaceUuid},
-			},
-
-			Template: coreV1.PodTemplateSpec{
-				ObjectMeta: metaV1.ObjectMeta{
-					Labels:    map[string]string{"lad_app": spaceUuid},
-					Namespace: k8sNameSpace,
-				},
-
-				Spec: coreV1.PodSpec{
-					NodeSelector: generateLabel(hardwareResource.Gpu.Unit),
-					Containers: []coreV1.Container{{
-						Name:            constants.K8S_CONTAINER_NAME_PREFIX + spaceUuid,
-						Image:           imageName,
-						ImagePullPolicy: coreV1.PullIfNotPresent,
-						Ports: []coreV1.ContainerPort{{
-							ContainerPort: int32(containerPort),
-						}},
-						Env: []coreV1.EnvVar{
-							{
-								Name:  "wallet_address",
-								Value: creatorWallet,
-							},
-							{
-								Name:  "space_uuid",
-								Value: spaceUuid,
-							},
-							{
-								Name:  "result_url",
-								Value: hostName,
-							},
-							{
-								Name:  "job_uuid",
-								Value: jobUuid,
-							},
-						},
-						Resources: coreV1.ResourceRequirements{
-							Limits: coreV1.ResourceList{
-								coreV1.ResourceCPU:              *resource.NewQuantity(hardwareResource.Cpu.Quantity, resource.DecimalSI),
-								coreV1.ResourceMemory:           memQuantity,
-								coreV1.ResourceEphemeralStorage: storageQuantity,
-								"nvidia.com/gpu":                resource.MustParse(fmt.Sprintf("%d", hardwareResource.Gpu.Quantity)),
-							},
-							Requests: coreV1.ResourceList{
-								coreV1.ResourceCPU:              *resource.NewQuantity(hardwareResource.Cpu.Quantity, resource.DecimalSI),
-								coreV1.ResourceMemory:           memQuantity,
-								coreV1.ResourceEphemeralStorage: storageQuantity,
-								"nvidia.com/gpu":                resource.MustParse(fmt.Sprintf("%d", hardwareResource.Gpu.Quantity)),
-							},
-						},
-					}},
-				},
-			},
-		}}
+	deployment := spaceDeployment(k8sNameSpace, spaceUuid, spaceUuid, 1, deployStrategy, template)
+	hardenPodSpec(&deployment.Spec.Template.Spec, creatorWallet, spaceUuid, hardwareResource)
 	createDeployment, err := k8sService.CreateDeployment(context.TODO(), k8sNameSpace, deployment)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
 	updateJobStatus(jobUuid, models.JobPullImage)
 	logs.GetLogger().Infof("Created deployment: %s", createDeployment.GetObjectMeta().GetName())
 
-	if err := deployK8sResource(k8sNameSpace, spaceUuid, hostName, containerPort); err != nil {
-		logs.GetLogger().Error(err)
-		return
+	portMappings, err := deployK8sResource(k8sNameSpace, spaceUuid, hostName, cr.Exposes)
+	if err != nil {
+		return err
 	}
-	updateJobStatus(jobUuid, models.JobDeployToK8s)
-
+	if err := waitForSpaceReady(k8sNameSpace, spaceUuid); err != nil {
+		return err
+	}
+	saveJobPorts(jobUuid, portMappings)
+	saveJobRecord(jobUuid, map[string]string{"host_name": hostName})
 	watchContainerRunningTime(jobUuid, k8sNameSpace, spaceUuid, int64(duration))
-	return
+	updateJobStatus(jobUuid, models.JobDeployToK8s)
+	return nil
 }
 
-func yamlToK8s(jobUuid, creatorWallet, spaceUuid, yamlPath, hostName string, hardwareResource models.Resource, duration int) {
+func yamlToK8s(jobUuid, creatorWallet, spaceUuid, yamlPath, hostName string, hardwareResource models.Resource, duration int) (err error) {
 	k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + creatorWallet
 	deleteJob(k8sNameSpace, spaceUuid)
 
 	containerResources, err := yaml.HandlerYaml(yamlPath)
 	if err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
 	if err := deployNamespace(creatorWallet); err != nil {
-		logs.GetLogger().Error(err)
-		return
+		return err
 	}
 
-	memQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardwareResource.Memory.Quantity, hardwareResource.Memory.Unit))
-	if err != nil {
-		logs.GetLogger().Error("get memory failed, error: %+v", err)
-		return
+	defer func() {
+		if err != nil {
+			teardownFailedSpace(k8sNameSpace, spaceUuid)
+		}
+	}()
+
+	secret := newSpaceSecret(spaceUuid)
+	defer func() {
+		err = secret.redact(err)
+	}()
+	for i := range containerResources {
+		secret.addServiceEnv(&containerResources[i])
+	}
+	if err := secret.apply(k8sNameSpace); err != nil {
+		return err
 	}
 
-	storageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardwareResource.Storage.Quantity, hardwareResource.Storage.Unit))
+	workloads, err := planWorkloads(spaceUuid, hostName, hardwareResource, containerResources)
 	if err != nil {
-		logs.GetLogger().Error("get storage failed, error: %+v", err)
-		return
+		return err
+	}
+	workloadAliases, err := createWorkloadServices(k8sNameSpace, workloads, containerResources)
+	if err != nil {
+		return err
 	}
 
 	k8sService := NewK8sService()
-	for _, cr := range containerResources {
-		for i, envVar := range cr.Env {
-			if strings.Contains(envVar.Name, "NEXTAUTH_URL") {
-				cr.Env[i].Value = "https://" + hostName
-				break
-			}
-		}
-
-		var volumeMount []coreV1.VolumeMount
+	var portMappings []models.PortMapping
+	var deployed bool
+	for i, cr := range containerResources {
+		workload := workloads[i]
 		var volumes []coreV1.Volume
+		var volumeMounts []coreV1.VolumeMount
 		if cr.VolumeMounts.Path != "" {
-			fileNameWithoutExt := filepath.Base(cr.VolumeMounts.Name[:len(cr.VolumeMounts.Name)-len(filepath.Ext(cr.VolumeMounts.Name))])
-			configMap, err := k8sService.CreateConfigMap(context.TODO(), k8sNameSpace, spaceUuid, filepath.Dir(yamlPath), cr.VolumeMounts.Name)
+			configMap, err := k8sService.CreateConfigMap(context.TODO(), k8sNameSpace, workload.Name, filepath.Dir(yamlPath), cr.VolumeMounts.Name)
 			if err != nil {
-				logs.GetLogger().Error(err)
-				return
-			}
-			configName := configMap.GetName()
-			volumes = []coreV1.Volume{
-				{
-					Name: spaceUuid + "-" + fileNameWithoutExt,
-					VolumeSource: coreV1.VolumeSource{
-						ConfigMap: &coreV1.ConfigMapVolumeSource{
-							LocalObjectReference: coreV1.LocalObjectReference{
-								Name: configName,
-							},
-						},
-					},
-				},
-			}
-			volumeMount = []coreV1.VolumeMount{
-				{
-					Name:      spaceUuid + "-" + fileNameWithoutExt,
-					MountPath: cr.VolumeMounts.Path,
-				},
+				return err
 			}
+			volumes, volumeMounts = configMapVolume(spaceUuid, configMap, cr.VolumeMounts)
 		}
 
-		var containers []coreV1.Container
-		for _, depend := range cr.Depends {
-			var handler = new(coreV1.ExecAction)
-			handler.Command = depend.ReadyCmd
-			containers = append(containers, coreV1.Container{
-				Name:            spaceUuid + "-" + depend.Name,
-				Image:           depend.ImageName,
-				Command:         depend.Command,
-				Args:            depend.Args,
-				Env:             depend.Env,
-				Ports:           depend.Ports,
-				ImagePullPolicy: coreV1.PullIfNotPresent,
-				Resources:       coreV1.ResourceRequirements{},
-				ReadinessProbe: &coreV1.Probe{
-					ProbeHandler: coreV1.ProbeHandler{
-						Exec: handler,
-					},
-					InitialDelaySeconds: 5,
-					PeriodSeconds:       5,
-				},
-			})
-		}
-
-		cr.Env = append(cr.Env, []coreV1.EnvVar{
-			{
-				Name:  "wallet_address",
-				Value: creatorWallet,
-			},
-			{
-				Name:  "space_uuid",
-				Value: spaceUuid,
-			},
-			{
-				Name:  "result_url",
-				Value: hostName,
-			},
-			{
-				Name:  "job_uuid",
-				Value: jobUuid,
-			},
-		}...)
-
-		containers = append(containers, coreV1.Container{
-			Name:            spaceUuid + "-" + cr.Name,
-			Image:           cr.ImageName,
-			Command:         cr.Command,
-			Args:            cr.Args,
-			Env:             cr.Env,
-			Ports:           cr.Ports,
-			ImagePullPolicy: coreV1.PullIfNotPresent,
-			Resources: coreV1.ResourceRequirements{
-				Limits: coreV1.ResourceList{
-					coreV1.ResourceCPU:              *resource.NewQuantity(hardwareResource.Cpu.Quantity, resource.DecimalSI),
-					coreV1.ResourceMemory:           memQuantity,
-					coreV1.ResourceEphemeralStorage: storageQuantity,
-					"nvidia.com/gpu":                resource.MustParse(fmt.Sprintf("%d", hardwareResource.Gpu.Quantity)),
-				},
-				Requests: coreV1.ResourceList{
-					coreV1.ResourceCPU:              *resource.NewQuantity(hardwareResource.Cpu.Quantity, resource.DecimalSI),
-					coreV1.ResourceMemory:           memQuantity,
-					coreV1.ResourceEphemeralStorage: storageQuantity,
-					"nvidia.com/gpu":                resource.MustParse(fmt.Sprintf("%d", hardwareResource.Gpu.Quantity)),
-				},
-			},
-			VolumeMounts: volumeMount,
-		})
+		replicas, autoscale, err := serviceReplicas(cr, workload.Hardware)
+		if err != nil {
+			return err
+		}
+		if cr.Batch != nil && len(cr.Depends) > 0 {
+			return fmt.Errorf("the batch service %s can not have depends-on services", cr.Name)
+		}
+		if cr.PersistentStorage != nil && (replicas > 1 || autoscale != nil) {
+			return fmt.Errorf("the service %s can not use persistent storage with more than one replica", cr.Name)
+		}
+		maxReplicas := int(replicas)
+		if autoscale != nil {
+			maxReplicas = autoscale.MaxReplicas
+		}
+		releaseGpu := reserveTaskGpu(gpuTaskKey(workload.Hardware), int(workload.Hardware.Gpu.Quantity)*(maxReplicas-1))
+		defer releaseGpu()
+
+		deployStrategy := appV1.DeploymentStrategy{}
+		volume, persistentMounts, err := spacePersistentVolume(k8sNameSpace, workload.Name, cr.PersistentStorage)
+		if err != nil {
+			return err
+		}
+		if volume != nil {
+			volumes = append(volumes, *volume)
+			volumeMounts = append(volumeMounts, persistentMounts...)
+			deployStrategy.Type = appV1.RecreateDeploymentStrategyType
+		}
 
-		deployment := &appV1.Deployment{
-			TypeMeta: metaV1.TypeMeta{
-				Kind:       "Deployment",
-				APIVersion: "apps/v1",
-			},
-			ObjectMeta: metaV1.ObjectMeta{
-				Name:      constants.K8S_DEPLOY_NAME_PREFIX + spaceUuid,
-				Namespace: k8sNameSpace,
-			},
-
-			Spec: appV1.DeploymentSpec{
-				Selector: &metaV1.LabelSelector{
-					MatchLabels: map[string]string{"lad_app": spaceUuid},
-				},
-				Template: coreV1.PodTemplateSpec{
-					ObjectMeta: metaV1.ObjectMeta{
-						Labels:    map[string]string{"lad_app": spaceUuid},
-						Namespace: k8sNameSpace,
-					},
-					Spec: coreV1.PodSpec{
-						NodeSelector: generateLabel(hardwareResource.Gpu.Unit),
-						Containers:   containers,
-						Volumes:      volumes,
-					},
-				},
-			}}
+		hostAliases, err := deployDependServices(creatorWallet, k8sNameSpace, workload.Name, cr.Depends, workload.DependResources)
+		if err != nil {
+			return err
+		}
+		hostAliases = append(hostAliases, workloadAliases...)
 
+		template, err := servicePodTemplate(jobUuid, creatorWallet, k8sNameSpace, spaceUuid, hostName, workload, cr, volumes, volumeMounts, hostAliases)
+		if err != nil {
+			return err
+		}
+		if cr.Batch != nil {
+			if err = runBatchJob(jobUuid, creatorWallet, k8sNameSpace, spaceUuid, workload, cr, template, duration); err != nil {
+				return err
+			}
+			continue
+		}
+
+		deployment := spaceDeployment(k8sNameSpace, spaceUuid, workload.Name, replicas, deployStrategy, template)
+		hardenPodSpec(&deployment.Spec.Template.Spec, creatorWallet, workload.Name, workload.Hardware)
 		createDeployment, err := k8sService.CreateDeployment(context.TODO(), k8sNameSpace, deployment)
 		if err != nil {
-			logs.GetLogger().Error(err)
-			return
+			return err
 		}
 
 		updateJobStatus(jobUuid, models.JobPullImage)
 		logs.GetLogger().Infof("Created deployment: %s", createDeployment.GetObjectMeta().GetName())
+		if err = deployAutoscaler(k8sNameSpace, workload.Name, autoscale); err != nil {
+			return err
+		}
 
-		if err := deployK8sResource(k8sNameSpace, spaceUuid, hostName, int64(cr.Ports[0].ContainerPort)); err != nil {
-			logs.GetLogger().Error(err)
-			return
+		servicePortMappings, err := deployK8sResource(k8sNameSpace, workload.Name, workload.HostName, cr.Exposes)
+		if err != nil {
+			return err
 		}
-		updateJobStatus(jobUuid, models.JobDeployToK8s)
+		portMappings = append(portMappings, servicePortMappings...)
 
+		if err := waitForSpaceReady(k8sNameSpace, workload.Name); err != nil {
+			return err
+		}
+		deployed = true
+	}
+	if deployed {
+		saveJobPorts(jobUuid, portMappings)
+		saveJobRecord(jobUuid, map[string]string{"host_name": hostName})
 		watchContainerRunningTime(jobUuid, k8sNameSpace, spaceUuid, int64(duration))
+		updateJobStatus(jobUuid, models.JobDeployToK8s)
 	}
+	return nil
+}
+
+func waitForSpaceReady(k8sNameSpace, spaceUuid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), getReadyTimeout())
+	defer cancel()
+	if err := NewK8sService().WaitForPodReady(ctx, k8sNameSpace, spaceUuid); err != nil {
+		return err
+	}
+	logs.GetLogger().Infof("Space is ready, namespace: %s, spaceUuid: %s", k8sNameSpace, spaceUuid)
+	return nil
+}
+
+func firstContainerPort(ports []coreV1.ContainerPort) int32 {
+	if len(ports) == 0 {
+		return 0
+	}
+	return ports[0].ContainerPort
 }
 
 func deployNamespace(creatorWallet string) error {
@@ -669,52 +634,92 @@ func deployNamespace(creatorWallet string) error {
 	// create namespace
 	if _, err := k8sService.GetNameSpace(context.TODO(), k8sNameSpace, metaV1.GetOptions{}); err != nil {
 		if errors.IsNotFound(err) {
-			namespace := &coreV1.Namespace{
-				ObjectMeta: metaV1.ObjectMeta{
-					Name: k8sNameSpace,
-					Labels: map[string]string{
-						"lab-ns": creatorWallet,
-					},
-				},
-			}
-			createdNamespace, err := k8sService.CreateNameSpace(context.TODO(), namespace, metaV1.CreateOptions{})
+			createdNamespace, err := k8sService.CreateNameSpace(context.TODO(), buildNamespace(creatorWallet), metaV1.CreateOptions{})
 			if err != nil {
 				return fmt.Errorf("failed create namespace, error: %w", err)
 			}
 			logs.GetLogger().Infof("create namespace successfully, namespace: %s", createdNamespace.Name)
-
-			//networkPolicy, err := k8sService.CreateNetworkPolicy(context.TODO(), k8sNameSpace)
-			//if err != nil {
-			//	return fmt.Errorf("failed create networkPolicy, error: %w", err)
-			//}
-			//logs.GetLogger().Infof("create networkPolicy successfully, networkPolicyName: %s", networkPolicy.Name)
 		} else {
 			return err
 		}
 	}
-	return nil
+	if err := applyPodSecurityLabels(k8sNameSpace); err != nil {
+		return err
+	}
+	if err := applyWalletQuota(k8sNameSpace); err != nil {
+		return err
+	}
+	return applyNetworkPolicies(k8sNameSpace)
 }
 
-func deployK8sResource(k8sNameSpace, spaceUuid, hostName string, containerPort int64) error {
+func deployK8sResource(k8sNameSpace, spaceUuid, hostName string, exposes []yaml.PortExpose) ([]models.PortMapping, error) {
 	k8sService := NewK8sService()
 
+	containerPorts := exposedContainerPorts(exposes)
+	if len(containerPorts) == 0 {
+		return nil, nil
+	}
+
 	// create service
-	createService, err := k8sService.CreateService(context.TODO(), k8sNameSpace, spaceUuid, int32(containerPort))
+	// the services of a multi-service space are created ahead of the deployments
+	createService, err := k8sService.CreateService(context.TODO(), k8sNameSpace, spaceUuid, containerPorts, httpPorts(exposes))
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed creata service, error: %w", err)
+	}
+	if err == nil {
+		logs.GetLogger().Infof("Created service successfully: %s", createService.GetObjectMeta().GetName())
+	}
+
+	routes, portMappings := ingressRoutes(hostName, exposes)
+
+	rawPortMappings, err := publishRawPorts(k8sNameSpace, spaceUuid, exposes)
+	if err != nil {
+		return nil, err
+	}
+	portMappings = append(portMappings, rawPortMappings...)
+	if len(routes) == 0 {
+		return portMappings, nil
+	}
+
+	tls, err := spaceIngressTLS(k8sNameSpace, spaceUuid)
 	if err != nil {
-		return fmt.Errorf("failed creata service, error: %w", err)
+		return nil, err
 	}
-	logs.GetLogger().Infof("Created service successfully: %s", createService.GetObjectMeta().GetName())
 
 	// create ingress
-	createIngress, err := k8sService.CreateIngress(context.TODO(), k8sNameSpace, spaceUuid, hostName, int32(containerPort))
+	createIngress, err := k8sService.CreateIngress(context.TODO(), k8sNameSpace, spaceUuid, routes, tls)
 	if err != nil {
-		return fmt.Errorf("failed creata ingress, error: %w", err)
+		return nil, fmt.Errorf("failed creata ingress, error: %w", err)
 	}
 	logs.GetLogger().Infof("Created Ingress successfully: %s", createIngress.GetObjectMeta().GetName())
-	return nil
+	attachCustomDomains(k8sNameSpace, spaceUuid)
+	return portMappings, nil
+}
+
+// portHostName returns the host name an additional port of a space is routed at: <prefix>-<port>.<domain>
+func portHostName(hostName string, port int32) string {
+	if i := strings.Index(hostName, "."); i > 0 {
+		return fmt.Sprintf("%s-%d%s", hostName[:i], port, hostName[i:])
+	}
+	return fmt.Sprintf("%s-%d", hostName, port)
 }
 
+// deleteJob deletes every workload of the space, the other services of a multi-service space before the primary one
 func deleteJob(namespace, spaceUuid string) {
+	workloads, err := NewK8sService().ListSpaceWorkloads(context.TODO(), namespace, spaceUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed list workloads of the space, spaceUuid: %s, error: %+v", spaceUuid, err)
+	}
+	for _, workload := range workloads {
+		if workload != spaceUuid {
+			deleteWorkload(namespace, workload)
+		}
+	}
+	deleteWorkload(namespace, spaceUuid)
+	deleteSpaceSecret(namespace, spaceUuid)
+}
+
+func deleteWorkload(namespace, spaceUuid string) {
 	deployName := constants.K8S_DEPLOY_NAME_PREFIX + spaceUuid
 	serviceName := constants.K8S_SERVICE_NAME_PREFIX + spaceUuid
 	ingressName := constants.K8S_INGRESS_NAME_PREFIX + spaceUuid
@@ -732,15 +737,20 @@ func deleteJob(namespace, spaceUuid string) {
 	}
 	logs.GetLogger().Infof("Deleted service %s finished", serviceName)
 
-	dockerService := docker.NewDockerService()
-	deployImageIds, err := k8sService.GetDeploymentImages(context.TODO(), namespace, deployName)
-	if err != nil && !errors.IsNotFound(err) {
-		logs.GetLogger().Errorf("Failed get deploy imageIds, deployName: %s, error: %+v", deployName, err)
+	hpaName := constants.K8S_HPA_NAME_PREFIX + spaceUuid
+	if err := k8sService.DeleteHorizontalPodAutoscaler(context.TODO(), namespace, hpaName); err != nil && !errors.IsNotFound(err) {
+		logs.GetLogger().Errorf("Failed delete autoscaler, hpaName: %s, error: %+v", hpaName, err)
 		return
 	}
-	for _, imageId := range deployImageIds {
-		dockerService.RemoveImage(imageId)
+
+	externalServiceName := serviceName + constants.K8S_EXTERNAL_SERVICE_SUFFIX
+	if err := k8sService.DeleteService(context.TODO(), namespace, externalServiceName); err != nil && !errors.IsNotFound(err) {
+		logs.GetLogger().Errorf("Failed delete service, serviceName: %s, error: %+v", externalServiceName, err)
+		return
 	}
+	deleteExternalTrafficPolicy(namespace, spaceUuid)
+	deleteBatchJob(namespace, spaceUuid)
+	deleteDependServices(namespace, spaceUuid)
 
 	if err := k8sService.DeleteDeployment(context.TODO(), namespace, deployName); err != nil && !errors.IsNotFound(err) {
 		logs.GetLogger().Errorf("Failed delete deployment, deployName: %s, error: %+v", deployName, err)
@@ -780,6 +790,20 @@ func deleteJob(namespace, spaceUuid string) {
 	}
 }
 
+// teardownFailedSpace deletes the workloads of a space whose deployment failed, no lease watcher ever frees them
+func teardownFailedSpace(namespace, spaceUuid string) {
+	logs.GetLogger().Warnf("Tear down the failed space, namespace: %s, spaceUuid: %s", namespace, spaceUuid)
+	deleteJob(namespace, spaceUuid)
+	releaseSpace(namespace, spaceUuid)
+}
+
+// releaseSpace frees the resources a space keeps across redeploys once its lease ended
+func releaseSpace(namespace, spaceUuid string) {
+	releaseSpaceVolume(namespace, spaceUuid)
+	releaseCustomDomains(namespace, spaceUuid)
+	releaseSpaceCertificates(namespace, spaceUuid)
+}
+
 func watchContainerRunningTime(key, namespace, spaceUuid string, runTime int64) {
 	conn := redisPool.Get()
 	_, err := conn.Do("SET", key, "wait-delete", "EX", runTime)
@@ -808,7 +832,7 @@ func watchContainerRunningTime(key, namespace, spaceUuid string, runTime int64)
 			case redis.Message:
 				if n.Channel == "__keyevent@0__:expired" && string(n.Data) == key {
 					logs.GetLogger().Infof("The namespace: %s, spaceUuid: %s, job has reached its runtime and will stop running.", namespace, spaceUuid)
-					deleteJob(namespace, spaceUuid)
+					getOrchestrator().Delete(namespace, spaceUuid)
 					redisPool.Get().Do("DEL", constants.REDIS_FULL_PREFIX+key)
 				}
 			case redis.Subscription:
@@ -820,6 +844,29 @@ func watchContainerRunningTime(key, namespace, spaceUuid string, runTime int64)
 	}()
 }
 
+// saveJobRecord stores fields in the redis record of the job, which is removed when the lease ends
+func saveJobRecord(jobUuid string, fields map[string]string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	fullArgs := []interface{}{constants.REDIS_FULL_PREFIX + jobUuid}
+	for key, val := range fields {
+		fullArgs = append(fullArgs, key, val)
+	}
+	if _, err := conn.Do("HSET", fullArgs...); err != nil {
+		logs.GetLogger().Errorf("Failed save job record, jobUuid: %s, error: %+v", jobUuid, err)
+	}
+}
+
+func saveJobPorts(jobUuid string, portMappings []models.PortMapping) {
+	ports, err := json.Marshal(portMappings)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed convert port mappings to json, error: %+v", err)
+		return
+	}
+	saveJobRecord(jobUuid, map[string]string{"ports": string(ports)})
+}
+
 func updateJobStatus(jobUuid string, jobStatus models.JobStatus) {
 	go func() {
 		deployingChan <- models.Job{
@@ -829,6 +876,26 @@ func updateJobStatus(jobUuid string, jobStatus models.JobStatus) {
 	}()
 }
 
+func updateJobFailed(jobUuid string, reason string) {
+	go func() {
+		deployingChan <- models.Job{
+			Uuid:    jobUuid,
+			Status:  models.JobDeployFailed,
+			Message: reason,
+		}
+	}()
+}
+
+func updateJobCompleted(jobUuid string, resultUrl string) {
+	go func() {
+		deployingChan <- models.Job{
+			Uuid:    jobUuid,
+			Status:  models.JobCompleted,
+			Message: resultUrl,
+		}
+	}()
+}
+
 func generateString(length int) string {
 	characters := "abcdefghijklmnopqrstuvwxyz"
 	numbers := "0123456789"
@@ -901,9 +968,10 @@ func getHardwareDetail(description string) models.Resource {
 		hardwareResource.Gpu.Quantity = 0
 		hardwareResource.Gpu.Unit = ""
 	} else {
-		hardwareResource.Gpu.Quantity = 1
-		oldName := strings.TrimSpace(confSplits[0])
-		hardwareResource.Gpu.Unit = strings.ReplaceAll(oldName, "Nvidia", "NVIDIA")
+		model, count, resourceName := parseGpuSpec(confSplits[0])
+		hardwareResource.Gpu.Quantity = count
+		hardwareResource.Gpu.Unit = model
+		hardwareResource.GpuResource = resourceName
 	}
 
 	cpuSplits := strings.Split(confSplits[1], " ")
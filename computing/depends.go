@@ -0,0 +1,212 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	dependRoleInit    = "init"
+	dependRoleSidecar = "sidecar"
+	dependRoleService = "service"
+)
+
+// dependRole returns the role of a depends-on service, sidecar when it declares none
+func dependRole(depend yaml.ContainerResource) (string, error) {
+	switch role := strings.ToLower(depend.Role); role {
+	case "", dependRoleSidecar:
+		return dependRoleSidecar, nil
+	case dependRoleInit, dependRoleService:
+		return role, nil
+	default:
+		return "", fmt.Errorf("the depends-on service %s has an unknown role %s, supported roles: init, sidecar, service", depend.Name, depend.Role)
+	}
+}
+
+// dependContainers returns the init containers and the sidecar containers of the depends-on services, in the order
+// they are declared, each runs within the given resources. The pod starts a sidecar only once the previous one passed
+// its ready command, so the service depending on them starts after all of them are ready.
+//
+// Kubernetes before 1.28 has no sidecar containers, their order is emulated with a postStart hook polling the ready
+// command: it only holds when the sidecar has a ready command or an exec health check, the sidecar is not restarted
+// in order when it fails later, and a ready command that never succeeds fails the container after the hook timeout
+// of the kubelet instead of waiting for the ready timeout of the space.
+func dependContainers(spaceUuid string, depends []yaml.ContainerResource, resources coreV1.ResourceRequirements) (initContainers, sidecars []coreV1.Container, err error) {
+	for _, depend := range depends {
+		role, err := dependRole(depend)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		container := coreV1.Container{
+			Name:            spaceUuid + "-" + depend.Name,
+			Image:           depend.ImageName,
+			Command:         depend.Command,
+			Args:            depend.Args,
+			Env:             depend.Env,
+			Ports:           depend.Ports,
+			ImagePullPolicy: coreV1.PullIfNotPresent,
+			Resources:       *resources.DeepCopy(),
+		}
+		switch role {
+		case dependRoleInit:
+			initContainers = append(initContainers, container)
+		case dependRoleSidecar:
+			readyCmd := dependReadyCmd(depend)
+			if len(readyCmd) > 0 {
+				container.ReadinessProbe = &coreV1.Probe{
+					ProbeHandler: coreV1.ProbeHandler{
+						Exec: &coreV1.ExecAction{Command: readyCmd},
+					},
+					InitialDelaySeconds: 5,
+					PeriodSeconds:       5,
+				}
+				// the kubelet starts the next container of the pod once the postStart hook returned
+				container.Lifecycle = &coreV1.Lifecycle{
+					PostStart: &coreV1.LifecycleHandler{
+						Exec: &coreV1.ExecAction{
+							Command: append([]string{"sh", "-c", `until "$0" "$@"; do sleep 1; done`}, readyCmd...),
+						},
+					},
+				}
+			}
+			if depend.HealthCheck != nil {
+				container.ReadinessProbe, _, _ = generateProbes(firstContainerPort(depend.Ports), depend.HealthCheck)
+			}
+			sidecars = append(sidecars, container)
+		}
+	}
+	return initContainers, sidecars, nil
+}
+
+// dependReadyCmd returns the command telling whether a depends-on service is ready
+func dependReadyCmd(depend yaml.ContainerResource) []string {
+	if len(depend.ReadyCmd) > 0 {
+		return depend.ReadyCmd
+	}
+	if depend.HealthCheck != nil && strings.ToLower(depend.HealthCheck.Type) == "exec" {
+		return depend.HealthCheck.Command
+	}
+	return nil
+}
+
+// deployDependServices deploys the depends-on services with the service role as their own deployment and service
+// running within the given resources, waits until they are ready, and returns the host aliases resolving their names
+// to their services
+func deployDependServices(creatorWallet, k8sNameSpace, spaceUuid string, depends []yaml.ContainerResource, resources coreV1.ResourceRequirements) ([]coreV1.HostAlias, error) {
+	k8sService := NewK8sService()
+	var hostAliases []coreV1.HostAlias
+	for _, depend := range depends {
+		if role, _ := dependRole(depend); role != dependRoleService {
+			continue
+		}
+		if len(depend.Ports) == 0 {
+			return nil, fmt.Errorf("the depends-on service %s with the service role must expose a port", depend.Name)
+		}
+
+		dependName := dependResourceName(spaceUuid, depend.Name)
+		deployment := dependDeployment(creatorWallet, k8sNameSpace, spaceUuid, depend, resources)
+		if _, err := k8sService.CreateDeployment(context.TODO(), k8sNameSpace, deployment); err != nil {
+			return nil, fmt.Errorf("failed create deployment of the depends-on service %s, error: %w", depend.Name, err)
+		}
+
+		service, err := k8sService.CreateDependService(context.TODO(), k8sNameSpace, spaceUuid, dependName, depend.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("failed create service of the depends-on service %s, error: %w", depend.Name, err)
+		}
+		hostAliases = append(hostAliases, coreV1.HostAlias{
+			IP:        service.Spec.ClusterIP,
+			Hostnames: []string{depend.Name},
+		})
+		logs.GetLogger().Infof("Created depends-on service %s, namespace: %s, service: %s", depend.Name, k8sNameSpace, service.Name)
+	}
+
+	for _, depend := range depends {
+		if role, _ := dependRole(depend); role != dependRoleService {
+			continue
+		}
+		if err := waitForSpaceReady(k8sNameSpace, dependResourceName(spaceUuid, depend.Name)); err != nil {
+			return nil, fmt.Errorf("the depends-on service %s is not ready, %w", depend.Name, err)
+		}
+	}
+	return hostAliases, nil
+}
+
+// dependDeployment builds the deployment of a depends-on service with the service role
+func dependDeployment(creatorWallet, k8sNameSpace, spaceUuid string, depend yaml.ContainerResource, resources coreV1.ResourceRequirements) *appV1.Deployment {
+	dependName := dependResourceName(spaceUuid, depend.Name)
+	labels := map[string]string{"lad_app": dependName}
+	container := coreV1.Container{
+		Name:            dependName,
+		Image:           depend.ImageName,
+		Command:         depend.Command,
+		Args:            depend.Args,
+		Env:             depend.Env,
+		Ports:           depend.Ports,
+		ImagePullPolicy: coreV1.PullIfNotPresent,
+		Resources:       *resources.DeepCopy(),
+	}
+	if readyCmd := dependReadyCmd(depend); len(readyCmd) > 0 {
+		container.ReadinessProbe = &coreV1.Probe{
+			ProbeHandler: coreV1.ProbeHandler{
+				Exec: &coreV1.ExecAction{Command: readyCmd},
+			},
+			InitialDelaySeconds: 5,
+			PeriodSeconds:       5,
+		}
+	} else {
+		container.ReadinessProbe, _, _ = generateProbes(firstContainerPort(depend.Ports), depend.HealthCheck)
+	}
+
+	replicas := int32(1)
+	deployment := &appV1.Deployment{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_DEPLOY_NAME_PREFIX + dependName,
+			Namespace: k8sNameSpace,
+			Labels:    map[string]string{"lad_space": spaceUuid},
+		},
+		Spec: appV1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metaV1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: coreV1.PodTemplateSpec{
+				ObjectMeta: metaV1.ObjectMeta{
+					Labels:    labels,
+					Namespace: k8sNameSpace,
+				},
+				Spec: coreV1.PodSpec{
+					NodeSelector: generateLabel(""),
+					Containers:   []coreV1.Container{container},
+				},
+			},
+		},
+	}
+	hardenPodSpec(&deployment.Spec.Template.Spec, creatorWallet, dependName, models.Resource{})
+	return deployment
+}
+
+// dependResourceName names the deployment, service and pods of a depends-on service with the service role
+func dependResourceName(spaceUuid, dependName string) string {
+	return spaceUuid + "-" + strings.ReplaceAll(strings.ToLower(dependName), "_", "-")
+}
+
+// deleteDependServices deletes the deployments and services of the depends-on services of the space
+func deleteDependServices(k8sNameSpace, spaceUuid string) {
+	if err := NewK8sService().DeleteDependResources(context.TODO(), k8sNameSpace, spaceUuid); err != nil {
+		logs.GetLogger().Errorf("Failed delete depends-on services, spaceUuid: %s, error: %+v", spaceUuid, err)
+	}
+}
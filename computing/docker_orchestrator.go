@@ -0,0 +1,582 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gomodule/redigo/redis"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+const (
+	dockerLabelSpace     = "lad_space"
+	dockerLabelNamespace = "lad_namespace"
+	dockerLabelWorkload  = "lad_workload"
+	// dockerLabelRoute is followed by a host name, its value is the container port the host name is routed to
+	dockerLabelRoute    = "lad_route."
+	dockerNetworkPrefix = "lad-"
+	dockerReadyInterval = 3 * time.Second
+	dockerMaxRestarts   = 3
+)
+
+// dockerOrchestrator runs every space on the Docker Engine of the host. The services of a space share a bridge
+// network and reach each other by name, their http ports are published on the loopback interface and routed by the
+// built-in proxy, their raw global ports are published on host ports allocated from Orchestrator.HostPortMin-HostPortMax.
+// Unlike a pod, the sidecars of a service do not share its localhost, they are reached by name too, and the writable
+// layer of a container is not limited. Batch services, replicas and autoscaling are not supported.
+type dockerOrchestrator struct {
+	dockerService *docker.DockerService
+	hostPorts     *hostPortAllocator
+}
+
+// invalidVolumeNameChars are the characters a docker volume name can not contain
+var invalidVolumeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+func newDockerOrchestrator() *dockerOrchestrator {
+	return &dockerOrchestrator{dockerService: docker.NewDockerService(), hostPorts: newHostPortAllocator()}
+}
+
+func (o *dockerOrchestrator) DeployDockerfile(jobUuid, hostName, creatorWallet, spaceUuid, imageName, dockerfilePath string, hardwareResource models.Resource, duration int) error {
+	cr, err := dockerfileResource(imageName, dockerfilePath)
+	if err != nil {
+		return err
+	}
+	cr.Name = spaceUuid
+	workloads, err := planWorkloads(spaceUuid, hostName, hardwareResource, []yaml.ContainerResource{cr})
+	if err != nil {
+		return err
+	}
+	return o.deploy(jobUuid, creatorWallet, spaceUuid, hostName, "", workloads, []yaml.ContainerResource{cr}, duration)
+}
+
+func (o *dockerOrchestrator) DeployYaml(jobUuid, creatorWallet, spaceUuid, yamlPath, hostName string, hardwareResource models.Resource, duration int) error {
+	containerResources, err := yaml.HandlerYaml(yamlPath)
+	if err != nil {
+		return err
+	}
+	workloads, err := planWorkloads(spaceUuid, hostName, hardwareResource, containerResources)
+	if err != nil {
+		return err
+	}
+	return o.deploy(jobUuid, creatorWallet, spaceUuid, hostName, filepath.Dir(yamlPath), workloads, containerResources, duration)
+}
+
+// deploy replaces the containers of the space, its volumes are kept. A space that fails to deploy is deleted, no lease
+// watcher ever frees it and its containers would otherwise be restarted by docker.
+func (o *dockerOrchestrator) deploy(jobUuid, creatorWallet, spaceUuid, hostName, configDir string, workloads []spaceWorkload,
+	containerResources []yaml.ContainerResource, duration int) (err error) {
+	for _, cr := range containerResources {
+		if cr.Batch != nil {
+			return fmt.Errorf("the batch service %s is not supported by the docker orchestrator", cr.Name)
+		}
+		if cr.Count > 1 || cr.Autoscale != nil {
+			return fmt.Errorf("the service %s can only run one replica on the docker orchestrator", cr.Name)
+		}
+	}
+
+	namespace := constants.K8S_NAMESPACE_NAME_PREFIX + creatorWallet
+	o.removeSpace(spaceUuid)
+	keepSpaceVolumes(spaceUuid)
+	defer func() {
+		if err != nil {
+			logs.GetLogger().Warnf("Tear down the failed space, namespace: %s, spaceUuid: %s", namespace, spaceUuid)
+			o.Delete(namespace, spaceUuid)
+		}
+	}()
+
+	ctx := context.TODO()
+	networkName := dockerNetworkPrefix + spaceUuid
+	if err = o.dockerService.EnsureNetwork(ctx, networkName, o.labels(namespace, spaceUuid, "")); err != nil {
+		return fmt.Errorf("failed create network of the space, error: %w", err)
+	}
+
+	var portMappings []models.PortMapping
+	for i, cr := range containerResources {
+		workload := workloads[i]
+		if err = o.startDepends(ctx, namespace, spaceUuid, networkName, workload, cr.Depends); err != nil {
+			return err
+		}
+
+		var spec docker.ContainerSpec
+		var servicePortMappings []models.PortMapping
+		spec, servicePortMappings, err = o.serviceContainer(jobUuid, creatorWallet, namespace, spaceUuid, hostName, networkName, configDir, workload, cr)
+		if err != nil {
+			return err
+		}
+		updateJobStatus(jobUuid, models.JobPullImage)
+		var containerId string
+		containerId, err = o.dockerService.StartContainer(ctx, spec)
+		o.hostPorts.release(spec.Ports)
+		if err != nil {
+			return err
+		}
+		logs.GetLogger().Infof("Started container: %s", spec.Name)
+
+		portMappings = append(portMappings, servicePortMappings...)
+		saveJobPorts(jobUuid, portMappings)
+		if workload.Primary {
+			saveJobRecord(jobUuid, map[string]string{"host_name": hostName})
+			watchContainerRunningTime(jobUuid, namespace, spaceUuid, int64(duration))
+		}
+
+		healthPort := firstContainerPort(cr.Ports)
+		if cr.HealthCheck != nil && cr.HealthCheck.Port != 0 {
+			healthPort = int32(cr.HealthCheck.Port)
+		}
+		if err = o.waitForReady(containerId, spec.Name, cr.HealthCheck, healthPort); err != nil {
+			return err
+		}
+	}
+	updateJobStatus(jobUuid, models.JobDeployToK8s)
+	return nil
+}
+
+// serviceContainer describes the container of a service, it runs within its share of the order
+func (o *dockerOrchestrator) serviceContainer(jobUuid, creatorWallet, namespace, spaceUuid, hostName, networkName, configDir string,
+	workload spaceWorkload, cr yaml.ContainerResource) (docker.ContainerSpec, []models.PortMapping, error) {
+	env := serviceEnv(creatorWallet, spaceUuid, hostName, jobUuid, append(cr.Env, cr.SecretEnv...))
+	spec := docker.ContainerSpec{
+		Name:       workload.Name,
+		Image:      cr.ImageName,
+		Entrypoint: cr.Command,
+		Cmd:        cr.Args,
+		Env:        dockerEnv(env),
+		Labels:     o.labels(namespace, spaceUuid, workload.Name),
+		Network:    networkName,
+		Aliases:    []string{cr.Name},
+		Restart:    true,
+	}
+	if cr.HealthCheck != nil && strings.ToLower(cr.HealthCheck.Type) == "exec" {
+		spec.HealthCmd = cr.HealthCheck.Command
+	}
+	applyDockerSecurityProfile(&spec)
+
+	spec.NanoCpus = workload.Resources.Limits.Cpu().MilliValue() * 1e6
+	spec.Memory = workload.Resources.Limits.Memory().Value()
+	if workload.Primary {
+		spec.Gpus = int(workload.Hardware.Gpu.Quantity)
+	}
+
+	if cr.VolumeMounts.Path != "" {
+		configPath, err := filepath.Abs(filepath.Join(configDir, cr.VolumeMounts.Name))
+		if err != nil {
+			return spec, nil, err
+		}
+		spec.Mounts = append(spec.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   configPath,
+			Target:   filepath.Join(cr.VolumeMounts.Path, cr.VolumeMounts.Name),
+			ReadOnly: true,
+		})
+	}
+	volume, persistentMounts, _, err := persistentVolumeOf(workload.Name, cr.PersistentStorage)
+	if err != nil {
+		return spec, nil, err
+	}
+	for _, persistentMount := range persistentMounts {
+		// docker can not mount a sub path of a volume, every path gets a volume of its own
+		volumeName := volume.Name
+		if persistentMount.SubPath != "" {
+			volumeName += "-" + invalidVolumeNameChars.ReplaceAllString(persistentMount.SubPath, "-")
+		}
+		spec.Mounts = append(spec.Mounts, mount.Mount{
+			Type:          mount.TypeVolume,
+			Source:        volumeName,
+			Target:        persistentMount.MountPath,
+			VolumeOptions: &mount.VolumeOptions{Labels: o.labels(namespace, spaceUuid, workload.Name)},
+		})
+	}
+
+	routes, portMappings := ingressRoutes(workload.HostName, cr.Exposes)
+	for _, route := range routes {
+		spec.Labels[dockerLabelRoute+route.Host] = strconv.Itoa(int(route.Port))
+		spec.Ports = append(spec.Ports, docker.ContainerPort{Port: route.Port, HostIp: "127.0.0.1"})
+	}
+	for _, expose := range cr.Exposes {
+		if expose.Http {
+			continue
+		}
+		protocol := strings.ToLower(string(expose.Protocol))
+		if !expose.Global {
+			portMappings = append(portMappings, models.PortMapping{
+				ContainerPort: expose.Port,
+				Protocol:      protocol,
+				Url:           fmt.Sprintf("%s://%s:%d", protocol, cr.Name, expose.Port),
+			})
+			continue
+		}
+		requestedPort := expose.Port
+		if expose.As != 0 {
+			requestedPort = expose.As
+		}
+		hostPort, err := o.hostPorts.allocate(context.TODO(), o.dockerService, requestedPort)
+		if err != nil {
+			o.hostPorts.release(spec.Ports)
+			return spec, nil, err
+		}
+		spec.Ports = append(spec.Ports, docker.ContainerPort{Port: expose.Port, Protocol: protocol, HostPort: hostPort})
+		portMappings = append(portMappings, models.PortMapping{
+			ContainerPort: expose.Port,
+			Protocol:      protocol,
+			Url:           fmt.Sprintf("%s://%s:%d", protocol, getPublicIp(), hostPort),
+		})
+	}
+	return spec, portMappings, nil
+}
+
+// startDepends runs the depends-on services of a service in the order they are declared: an init service has to
+// exit successfully, a sidecar or a service has to pass its ready command before the next one starts
+func (o *dockerOrchestrator) startDepends(ctx context.Context, namespace, spaceUuid, networkName string, workload spaceWorkload, depends []yaml.ContainerResource) error {
+	for _, depend := range depends {
+		role, err := dependRole(depend)
+		if err != nil {
+			return err
+		}
+
+		dependName := dependResourceName(workload.Name, depend.Name)
+		spec := docker.ContainerSpec{
+			Name:       dependName,
+			Image:      depend.ImageName,
+			Entrypoint: depend.Command,
+			Cmd:        depend.Args,
+			Env:        dockerEnv(append(depend.Env, depend.SecretEnv...)),
+			Labels:     o.labels(namespace, spaceUuid, dependName),
+			Network:    networkName,
+			Aliases:    []string{depend.Name},
+			Restart:    role != dependRoleInit,
+			NanoCpus:   workload.DependResources.Limits.Cpu().MilliValue() * 1e6,
+			Memory:     workload.DependResources.Limits.Memory().Value(),
+		}
+		if role != dependRoleInit {
+			spec.HealthCmd = dependReadyCmd(depend)
+		}
+		applyDockerSecurityProfile(&spec)
+
+		containerId, err := o.dockerService.StartContainer(ctx, spec)
+		if err != nil {
+			return fmt.Errorf("failed start the depends-on service %s, error: %w", depend.Name, err)
+		}
+		if role == dependRoleInit {
+			exitCode, err := o.dockerService.WaitContainer(ctx, containerId)
+			if err != nil {
+				return fmt.Errorf("failed wait for the init service %s, error: %w", depend.Name, err)
+			}
+			if exitCode != 0 {
+				return fmt.Errorf("the init service %s exited with code %d", depend.Name, exitCode)
+			}
+			continue
+		}
+		if err = o.waitForReady(containerId, dependName, depend.HealthCheck, firstContainerPort(depend.Ports)); err != nil {
+			return fmt.Errorf("the depends-on service %s is not ready, %w", depend.Name, err)
+		}
+		logs.GetLogger().Infof("Started depends-on service %s, container: %s", depend.Name, dependName)
+	}
+	return nil
+}
+
+// waitForReady waits until the container is running and healthy. Without a docker health check, an http or tcp
+// health check is probed through the port published on the loopback interface, when there is one.
+func (o *dockerOrchestrator) waitForReady(containerId, name string, healthCheck *yaml.HealthCheck, healthPort int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), getReadyTimeout())
+	defer cancel()
+
+	ticker := time.NewTicker(dockerReadyInterval)
+	defer ticker.Stop()
+	for {
+		info, err := o.dockerService.InspectContainer(ctx, containerId)
+		if err != nil {
+			return fmt.Errorf("failed inspect container %s, error: %w", name, err)
+		}
+		if info.RestartCount >= dockerMaxRestarts {
+			return fmt.Errorf("the container %s keeps restarting, exit code: %d", name, info.State.ExitCode)
+		}
+		if info.State.Status == "exited" || info.State.Status == "dead" {
+			return fmt.Errorf("the container %s exited with code %d %s", name, info.State.ExitCode, info.State.Error)
+		}
+		if info.State.Running && containerHealthy(info, healthCheck, healthPort) {
+			logs.GetLogger().Infof("Container is ready: %s", name)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the container %s is not ready after %s", name, getReadyTimeout())
+		case <-ticker.C:
+		}
+	}
+}
+
+func containerHealthy(info types.ContainerJSON, healthCheck *yaml.HealthCheck, healthPort int32) bool {
+	if info.State.Health != nil {
+		return info.State.Health.Status == types.Healthy
+	}
+	if healthCheck == nil || info.NetworkSettings == nil {
+		return true
+	}
+
+	var hostPort string
+	for _, binding := range info.NetworkSettings.Ports[nat.Port(fmt.Sprintf("%d/tcp", healthPort))] {
+		if binding.HostIP == "127.0.0.1" {
+			hostPort = binding.HostPort
+		}
+	}
+	if hostPort == "" {
+		return true
+	}
+
+	address := net.JoinHostPort("127.0.0.1", hostPort)
+	if strings.ToLower(healthCheck.Type) == "http" || (healthCheck.Type == "" && healthCheck.Path != "") {
+		client := http.Client{Timeout: 3 * time.Second}
+		resp, err := client.Get("http://" + address + healthCheck.Path)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest
+	}
+	conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// labels returns the labels of the containers, the network and the volumes of a space
+func (o *dockerOrchestrator) labels(namespace, spaceUuid, workload string) map[string]string {
+	labels := map[string]string{
+		dockerLabelSpace:     spaceUuid,
+		dockerLabelNamespace: namespace,
+	}
+	if workload != "" {
+		labels[dockerLabelWorkload] = workload
+	}
+	return labels
+}
+
+// removeSpace removes the containers and the network of the space
+func (o *dockerOrchestrator) removeSpace(spaceUuid string) {
+	ctx := context.TODO()
+	if err := o.dockerService.RemoveContainers(ctx, map[string]string{dockerLabelSpace: spaceUuid}); err != nil {
+		logs.GetLogger().Errorf("Failed remove containers, spaceUuid: %s, error: %+v", spaceUuid, err)
+	}
+	if err := o.dockerService.RemoveNetwork(ctx, dockerNetworkPrefix+spaceUuid); err != nil {
+		logs.GetLogger().Errorf("Failed remove network, spaceUuid: %s, error: %+v", spaceUuid, err)
+	}
+}
+
+// Delete removes the space, its volumes are removed once Volume.RetainHours passed unless the space is redeployed
+func (o *dockerOrchestrator) Delete(namespace, spaceUuid string) {
+	o.removeSpace(spaceUuid)
+	releaseSpaceVolumes(spaceUuid)
+	releaseCustomDomains(namespace, spaceUuid)
+	logs.GetLogger().Infof("Deleted space finished, spaceUuid: %s", spaceUuid)
+}
+
+// releaseSpaceVolumes schedules the removal of the volumes of the space after the retain period
+func releaseSpaceVolumes(spaceUuid string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+	releaseAt := time.Now().Add(volumeRetain())
+	if _, err := conn.Do("ZADD", constants.REDIS_VOLUME_RELEASE_KEY, releaseAt.Unix(), spaceUuid); err != nil {
+		logs.GetLogger().Errorf("Failed release volumes, spaceUuid: %s, error: %+v", spaceUuid, err)
+		return
+	}
+	logs.GetLogger().Infof("Released volumes of space %s, they will be removed after %s", spaceUuid, volumeRetain())
+}
+
+// keepSpaceVolumes cancels the removal of the volumes of a space deployed again within the retain period
+func keepSpaceVolumes(spaceUuid string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("ZREM", constants.REDIS_VOLUME_RELEASE_KEY, spaceUuid); err != nil {
+		logs.GetLogger().Errorf("Failed keep volumes, spaceUuid: %s, error: %+v", spaceUuid, err)
+	}
+}
+
+// removeReleasedVolumes removes the volumes of the spaces whose retain period passed
+func (o *dockerOrchestrator) removeReleasedVolumes() {
+	conn := redisPool.Get()
+	defer conn.Close()
+	spaceUuids, err := redis.Strings(conn.Do("ZRANGEBYSCORE", constants.REDIS_VOLUME_RELEASE_KEY, "-inf", time.Now().Unix()))
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get released volumes, error: %+v", err)
+		return
+	}
+	for _, spaceUuid := range spaceUuids {
+		if err = o.dockerService.RemoveVolumes(context.TODO(), map[string]string{dockerLabelSpace: spaceUuid}); err != nil {
+			logs.GetLogger().Errorf("Failed remove volumes, spaceUuid: %s, error: %+v", spaceUuid, err)
+			continue
+		}
+		conn.Do("ZREM", constants.REDIS_VOLUME_RELEASE_KEY, spaceUuid)
+		logs.GetLogger().Infof("Removed released volumes of space %s", spaceUuid)
+	}
+}
+
+func (o *dockerOrchestrator) Status(namespace, spaceUuid string) ([]models.SpaceContainer, error) {
+	containers, err := o.spaceContainers(spaceUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var spaceContainers []models.SpaceContainer
+	for _, c := range containers {
+		info, err := o.dockerService.InspectContainer(context.TODO(), c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed inspect container, error: %w", err)
+		}
+		spaceContainer := models.SpaceContainer{
+			Name:     strings.TrimPrefix(info.Name, "/"),
+			Workload: c.Labels[dockerLabelWorkload],
+			State:    info.State.Status,
+			Ready:    info.State.Running && (info.State.Health == nil || info.State.Health.Status == types.Healthy),
+			Restarts: int32(info.RestartCount),
+		}
+		if !info.State.Running {
+			spaceContainer.Message = strings.TrimSpace(fmt.Sprintf("exit code: %d %s", info.State.ExitCode, info.State.Error))
+		}
+		spaceContainers = append(spaceContainers, spaceContainer)
+	}
+	return spaceContainers, nil
+}
+
+func (o *dockerOrchestrator) Logs(namespace, spaceUuid string, tailLines int64) (string, error) {
+	containers, err := o.spaceContainers(spaceUuid)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, c := range containers {
+		containerLog, err := o.dockerService.ContainerLogs(context.TODO(), c.ID, tailLines)
+		if err != nil {
+			containerLog = err.Error() + "\n"
+		}
+		fmt.Fprintf(&out, "==> %s/%s <==\n%s", c.Labels[dockerLabelWorkload], strings.TrimPrefix(c.Names[0], "/"), containerLog)
+	}
+	return out.String(), nil
+}
+
+func (o *dockerOrchestrator) spaceContainers(spaceUuid string) ([]types.Container, error) {
+	containers, err := o.dockerService.ListContainers(context.TODO(), map[string]string{dockerLabelSpace: spaceUuid})
+	if err != nil {
+		return nil, fmt.Errorf("failed list containers of the space, error: %w", err)
+	}
+	return containers, nil
+}
+
+// NodeResources returns the resources of the host, the ones used are what the running spaces are limited to
+func (o *dockerOrchestrator) NodeResources(ctx context.Context) ([]*models.NodeResource, error) {
+	info, err := o.dockerService.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed get docker info, error: %w", err)
+	}
+	containers, err := o.dockerService.ListContainers(ctx, map[string]string{dockerLabelSpace: ""})
+	if err != nil {
+		return nil, fmt.Errorf("failed list containers, error: %w", err)
+	}
+
+	var usedNanoCpus, usedMem int64
+	var usedGpu int
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		containerInfo, err := o.dockerService.InspectContainer(ctx, c.ID)
+		if err != nil || containerInfo.HostConfig == nil {
+			continue
+		}
+		usedNanoCpus += containerInfo.HostConfig.NanoCPUs
+		usedMem += containerInfo.HostConfig.Memory
+		for _, deviceRequest := range containerInfo.HostConfig.DeviceRequests {
+			usedGpu += deviceRequest.Count
+		}
+	}
+
+	var nodeResource = new(models.NodeResource)
+	nodeResource.MachineId = info.ID
+	nodeResource.Model = info.Architecture
+
+	usedCpu := usedNanoCpus / 1e9
+	nodeResource.Cpu.Total = strconv.Itoa(info.NCPU)
+	nodeResource.Cpu.Used = strconv.FormatInt(usedCpu, 10)
+	nodeResource.Cpu.Free = strconv.FormatInt(int64(info.NCPU)-usedCpu, 10)
+	nodeResource.Vcpu = nodeResource.Cpu
+
+	nodeResource.Memory.Total = fmt.Sprintf("%.2f GiB", float64(info.MemTotal/1024/1024/1024))
+	nodeResource.Memory.Used = fmt.Sprintf("%.2f GiB", float64(usedMem/1024/1024/1024))
+	nodeResource.Memory.Free = fmt.Sprintf("%.2f GiB", float64((info.MemTotal-usedMem)/1024/1024/1024))
+
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(info.DockerRootDir, &stat); err == nil {
+		totalStorage := int64(stat.Blocks) * int64(stat.Bsize)
+		freeStorage := int64(stat.Bavail) * int64(stat.Bsize)
+		nodeResource.Storage.Total = fmt.Sprintf("%.2f GiB", float64(totalStorage/1024/1024/1024))
+		nodeResource.Storage.Used = fmt.Sprintf("%.2f GiB", float64((totalStorage-freeStorage)/1024/1024/1024))
+		nodeResource.Storage.Free = fmt.Sprintf("%.2f GiB", float64(freeStorage/1024/1024/1024))
+	}
+
+	nodeResource.Gpu = hostGpus(usedGpu)
+	return []*models.NodeResource{nodeResource}, nil
+}
+
+// hostGpus lists the GPUs of the host with nvidia-smi, the first used ones are reported as occupied
+func hostGpus(usedGpu int) models.Gpu {
+	var gpu models.Gpu
+	out, err := exec.Command("nvidia-smi", "--query-gpu=name,memory.total,memory.used", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return gpu
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		total, _ := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		used, _ := strconv.ParseInt(strings.TrimSpace(fields[2]), 10, 64)
+		status := models.Available
+		if len(gpu.Details) < usedGpu {
+			status = models.Occupied
+		}
+		gpu.Details = append(gpu.Details, models.GpuDetail{
+			ProductName: strings.TrimSpace(fields[0]),
+			Status:      status,
+			FbMemoryUsage: models.Common{
+				Total: fmt.Sprintf("%d MiB", total),
+				Used:  fmt.Sprintf("%d MiB", used),
+				Free:  fmt.Sprintf("%d MiB", total-used),
+			},
+		})
+	}
+	gpu.AttachedGpus = len(gpu.Details)
+	return gpu
+}
+
+// dockerEnv converts env vars to the KEY=value form of docker, the ones referencing a Kubernetes object are skipped
+func dockerEnv(envVars []coreV1.EnvVar) []string {
+	var env []string
+	for _, envVar := range envVars {
+		if envVar.ValueFrom != nil {
+			continue
+		}
+		env = append(env, envVar.Name+"="+envVar.Value)
+	}
+	return env
+}
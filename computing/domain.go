@@ -0,0 +1,373 @@
+package computing
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+	"github.com/lagrangedao/go-computing-provider/common"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+var domainRegexp = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+const (
+	domainActionAdd    = "add"
+	domainActionVerify = "verify"
+	domainActionDelete = "delete"
+)
+
+// customDomainReq is signed by the wallet of the space with personal_sign, the signed message is
+// <action>:<job_uuid>:<domain> where action is add, verify or delete
+type customDomainReq struct {
+	JobUuid   string `json:"job_uuid"`
+	Domain    string `json:"domain"`
+	Signature string `json:"signature"`
+}
+
+// AddCustomDomain registers a custom domain for the space of a job, and returns the DNS records proving its ownership
+func AddCustomDomain(c *gin.Context) {
+	var req customDomainReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	domain, err := checkCustomDomain(req.Domain)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobRecord, err := getJobRecord(req.JobUuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err = authorizeDomainRequest(domainActionAdd, jobRecord, domain, req.Signature); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err = claimCustomDomain(jobRecord.SpaceUuid, domain); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	customDomain, err := getCustomDomain(jobRecord.SpaceUuid, domain)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if customDomain == nil {
+		customDomain = &models.CustomDomain{
+			Domain: domain,
+			Token:  generateString(32),
+		}
+		if err = saveCustomDomain(jobRecord.SpaceUuid, customDomain); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, common.CreateSuccessResponse(domainChallenge(customDomain, jobRecord.HostName)))
+}
+
+// VerifyCustomDomain checks the ownership record of a custom domain and routes the domain to the space
+func VerifyCustomDomain(c *gin.Context) {
+	var req customDomainReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	domain, err := checkCustomDomain(req.Domain)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobRecord, err := getJobRecord(req.JobUuid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err = authorizeDomainRequest(domainActionVerify, jobRecord, domain, req.Signature); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err = claimCustomDomain(jobRecord.SpaceUuid, domain); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	customDomain, err := getCustomDomain(jobRecord.SpaceUuid, domain)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if customDomain == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found, please add it first"})
+		return
+	}
+
+	if !customDomain.Verified {
+		if !hasTXTRecord(constants.DOMAIN_CHALLENGE_PREFIX+domain, customDomain.Token) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ownership verification failed, TXT record %s not found",
+				constants.DOMAIN_CHALLENGE_PREFIX+domain)})
+			return
+		}
+		customDomain.Verified = true
+		if err = saveCustomDomain(jobRecord.SpaceUuid, customDomain); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err = attachCustomDomain(jobRecord.Namespace, jobRecord.SpaceUuid, domain); err != nil {
+		logs.GetLogger().Errorf("Failed attach custom domain %s, spaceUuid: %s, error: %+v", domain, jobRecord.SpaceUuid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.CreateSuccessResponse(domainChallenge(customDomain, jobRecord.HostName)))
+}
+
+func DeleteCustomDomain(c *gin.Context) {
+	domain, err := checkCustomDomain(c.Query("domain"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jobRecord, err := getJobRecord(c.Query("job_uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err = authorizeDomainRequest(domainActionDelete, jobRecord, domain, c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err = detachCustomDomain(jobRecord.Namespace, jobRecord.SpaceUuid, domain); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+	if _, err = conn.Do("HDEL", constants.REDIS_DOMAIN_PREFIX+jobRecord.SpaceUuid, domain); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	unclaimCustomDomain(conn, jobRecord.SpaceUuid, domain)
+	c.JSON(http.StatusOK, common.CreateSuccessResponse("deleted success"))
+}
+
+// authorizeDomainRequest checks that the request is signed by the wallet owning the space of the job
+func authorizeDomainRequest(action string, jobRecord *models.JobRecord, domain, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("signature is required")
+	}
+	message := fmt.Sprintf("%s:%s:%s", action, jobRecord.JobUuid, domain)
+	return verifyWalletSignature(namespaceWallet(jobRecord.Namespace), message, signature)
+}
+
+// claimCustomDomain makes the space the only one the domain can be added to, until the space releases it
+func claimCustomDomain(spaceUuid, domain string) error {
+	conn := redisPool.Get()
+	defer conn.Close()
+	key := constants.REDIS_DOMAIN_OWNER_PREFIX + domain
+	if _, err := conn.Do("SET", key, spaceUuid, "NX"); err != nil {
+		return err
+	}
+	owner, err := redis.String(conn.Do("GET", key))
+	if err != nil {
+		return err
+	}
+	if owner != spaceUuid {
+		return fmt.Errorf("domain %s is already used by another space", domain)
+	}
+	return nil
+}
+
+// unclaimCustomDomain frees the domain when the space owns it
+func unclaimCustomDomain(conn redis.Conn, spaceUuid, domain string) {
+	key := constants.REDIS_DOMAIN_OWNER_PREFIX + domain
+	if owner, err := redis.String(conn.Do("GET", key)); err == nil && owner == spaceUuid {
+		conn.Do("DEL", key)
+	}
+}
+
+func checkCustomDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if !domainRegexp.MatchString(domain) {
+		return "", fmt.Errorf("invalid domain: %s", domain)
+	}
+	providerDomain := strings.TrimPrefix(conf.GetConfig().API.Domain, ".")
+	if providerDomain != "" && (domain == providerDomain || strings.HasSuffix(domain, "."+providerDomain)) {
+		return "", fmt.Errorf("domain %s belongs to the provider", domain)
+	}
+	return domain, nil
+}
+
+func domainChallenge(customDomain *models.CustomDomain, hostName string) models.DomainChallenge {
+	return models.DomainChallenge{
+		Domain:      customDomain.Domain,
+		RecordName:  constants.DOMAIN_CHALLENGE_PREFIX + customDomain.Domain,
+		RecordValue: customDomain.Token,
+		CName:       hostName,
+		Verified:    customDomain.Verified,
+	}
+}
+
+func hasTXTRecord(name, value string) bool {
+	records, err := net.LookupTXT(name)
+	if err != nil {
+		logs.GetLogger().Warnf("Failed lookup TXT record %s, error: %v", name, err)
+		return false
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func getCustomDomains(spaceUuid string) ([]models.CustomDomain, error) {
+	conn := redisPool.Get()
+	defer conn.Close()
+	values, err := redis.StringMap(conn.Do("HGETALL", constants.REDIS_DOMAIN_PREFIX+spaceUuid))
+	if err != nil {
+		return nil, err
+	}
+
+	var customDomains []models.CustomDomain
+	for _, value := range values {
+		var customDomain models.CustomDomain
+		if err = json.Unmarshal([]byte(value), &customDomain); err != nil {
+			return nil, err
+		}
+		customDomains = append(customDomains, customDomain)
+	}
+	return customDomains, nil
+}
+
+func getCustomDomain(spaceUuid, domain string) (*models.CustomDomain, error) {
+	conn := redisPool.Get()
+	defer conn.Close()
+	value, err := redis.String(conn.Do("HGET", constants.REDIS_DOMAIN_PREFIX+spaceUuid, domain))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var customDomain models.CustomDomain
+	if err = json.Unmarshal([]byte(value), &customDomain); err != nil {
+		return nil, err
+	}
+	return &customDomain, nil
+}
+
+func saveCustomDomain(spaceUuid string, customDomain *models.CustomDomain) error {
+	value, err := json.Marshal(customDomain)
+	if err != nil {
+		return err
+	}
+	conn := redisPool.Get()
+	defer conn.Close()
+	_, err = conn.Do("HSET", constants.REDIS_DOMAIN_PREFIX+spaceUuid, customDomain.Domain, string(value))
+	return err
+}
+
+func customDomainSecretName(spaceUuid, domain string) string {
+	hash := sha1.Sum([]byte(domain))
+	return constants.K8S_TLS_SECRET_PREFIX + spaceUuid + "-" + hex.EncodeToString(hash[:])[:8]
+}
+
+// attachCustomDomain adds a verified custom domain to the ingress of the space. Its certificate is issued by the
+// ClusterIssuer: through the ingress annotation, or with a dedicated certificate when a wildcard secret is used.
+func attachCustomDomain(namespace, spaceUuid, domain string) error {
+	if isDockerOrchestrator() {
+		return fmt.Errorf("custom domains are not supported by the docker orchestrator")
+	}
+	tlsConf := conf.GetConfig().TLS
+	if tlsConf.ClusterIssuer == "" {
+		return fmt.Errorf("custom domains require TLS.ClusterIssuer to issue certificates")
+	}
+
+	k8sService := NewK8sService()
+	secretName := customDomainSecretName(spaceUuid, domain)
+	if tlsConf.SecretName != "" {
+		if err := k8sService.CreateCertificate(context.TODO(), namespace, secretName, secretName, tlsConf.ClusterIssuer, []string{domain}); err != nil {
+			return fmt.Errorf("failed create certificate, error: %w", err)
+		}
+	}
+	if err := k8sService.AddIngressHost(context.TODO(), namespace, constants.K8S_INGRESS_NAME_PREFIX+spaceUuid, domain, secretName); err != nil {
+		return fmt.Errorf("failed add domain to ingress, error: %w", err)
+	}
+	logs.GetLogger().Infof("Attached custom domain %s to space %s", domain, spaceUuid)
+	return nil
+}
+
+// attachCustomDomains adds every verified custom domain to the recreated ingress of a space
+func attachCustomDomains(namespace, spaceUuid string) {
+	customDomains, err := getCustomDomains(spaceUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get custom domains, spaceUuid: %s, error: %+v", spaceUuid, err)
+		return
+	}
+	for _, customDomain := range customDomains {
+		if !customDomain.Verified {
+			continue
+		}
+		if err = attachCustomDomain(namespace, spaceUuid, customDomain.Domain); err != nil {
+			logs.GetLogger().Errorf("Failed attach custom domain %s, spaceUuid: %s, error: %+v", customDomain.Domain, spaceUuid, err)
+		}
+	}
+}
+
+func detachCustomDomain(namespace, spaceUuid, domain string) error {
+	if isDockerOrchestrator() {
+		return nil
+	}
+	k8sService := NewK8sService()
+	err := k8sService.RemoveIngressHost(context.TODO(), namespace, constants.K8S_INGRESS_NAME_PREFIX+spaceUuid, domain)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed remove domain from ingress, error: %w", err)
+	}
+	secretName := customDomainSecretName(spaceUuid, domain)
+	if err = k8sService.DeleteCertificate(context.TODO(), namespace, secretName, secretName); err != nil {
+		return fmt.Errorf("failed delete certificate, error: %w", err)
+	}
+	return nil
+}
+
+// releaseCustomDomains removes the custom domains of a space whose lease ended
+func releaseCustomDomains(namespace, spaceUuid string) {
+	customDomains, err := getCustomDomains(spaceUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get custom domains, spaceUuid: %s, error: %+v", spaceUuid, err)
+		return
+	}
+	for _, customDomain := range customDomains {
+		if err = detachCustomDomain(namespace, spaceUuid, customDomain.Domain); err != nil {
+			logs.GetLogger().Errorf("Failed detach custom domain %s, spaceUuid: %s, error: %+v", customDomain.Domain, spaceUuid, err)
+		}
+	}
+
+	conn := redisPool.Get()
+	defer conn.Close()
+	for _, customDomain := range customDomains {
+		unclaimCustomDomain(conn, spaceUuid, customDomain.Domain)
+	}
+	conn.Do("DEL", constants.REDIS_DOMAIN_PREFIX+spaceUuid)
+}
@@ -0,0 +1,162 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultNodePortMin = 30000
+	defaultNodePortMax = 32767
+)
+
+// publishRawPorts publishes the raw global ports of a space with an external service,
+// and returns the endpoint every raw port of the space is reachable at
+func publishRawPorts(k8sNameSpace, spaceUuid string, exposes []yaml.PortExpose) ([]models.PortMapping, error) {
+	serviceType := coreV1.ServiceTypeNodePort
+	if strings.EqualFold(conf.GetConfig().Network.ServiceType, string(coreV1.ServiceTypeLoadBalancer)) {
+		serviceType = coreV1.ServiceTypeLoadBalancer
+	}
+
+	k8sService := NewK8sService()
+	var servicePorts []coreV1.ServicePort
+	var portMappings []models.PortMapping
+	for _, expose := range exposes {
+		if expose.Http {
+			continue
+		}
+		protocol := strings.ToLower(string(expose.Protocol))
+		if !expose.Global {
+			portMappings = append(portMappings, models.PortMapping{
+				ContainerPort: expose.Port,
+				Protocol:      protocol,
+				Url: fmt.Sprintf("%s://%s.%s.svc.cluster.local:%d", protocol,
+					constants.K8S_SERVICE_NAME_PREFIX+spaceUuid, k8sNameSpace, expose.Port),
+			})
+			continue
+		}
+
+		servicePort := coreV1.ServicePort{
+			Name:       fmt.Sprintf("%s-%d", protocol, expose.Port),
+			Port:       expose.Port,
+			TargetPort: intstr.FromInt(int(expose.Port)),
+			Protocol:   expose.Protocol,
+		}
+		if serviceType == coreV1.ServiceTypeLoadBalancer {
+			if expose.As != 0 {
+				servicePort.Port = expose.As
+			}
+		} else {
+			servicePort.NodePort = requestedNodePort(expose.As)
+		}
+		servicePorts = append(servicePorts, servicePort)
+	}
+	if len(servicePorts) == 0 {
+		return portMappings, nil
+	}
+
+	service, err := k8sService.CreateExternalService(context.TODO(), k8sNameSpace, spaceUuid, serviceType, servicePorts)
+	if errors.IsInvalid(err) && hasRequestedNodePort(servicePorts) {
+		// a requested node port is taken, let the apiserver allocate every port
+		logs.GetLogger().Warnf("Requested node ports are unavailable, spaceUuid: %s, error: %v", spaceUuid, err)
+		for i := range servicePorts {
+			servicePorts[i].NodePort = 0
+		}
+		service, err = k8sService.CreateExternalService(context.TODO(), k8sNameSpace, spaceUuid, serviceType, servicePorts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed create external service, error: %w", err)
+	}
+	logs.GetLogger().Infof("Created external service successfully: %s", service.GetName())
+	if err = allowExternalTraffic(k8sNameSpace, spaceUuid, servicePorts); err != nil {
+		return nil, fmt.Errorf("failed allow external traffic, error: %w", err)
+	}
+
+	var host string
+	if serviceType == coreV1.ServiceTypeLoadBalancer {
+		host = waitLoadBalancerAddress(k8sNameSpace, service.GetName())
+	} else {
+		host = getPublicIp()
+	}
+	for _, port := range service.Spec.Ports {
+		portMapping := models.PortMapping{
+			ContainerPort: port.TargetPort.IntVal,
+			Protocol:      strings.ToLower(string(port.Protocol)),
+		}
+		externalPort := port.NodePort
+		if serviceType == coreV1.ServiceTypeLoadBalancer {
+			externalPort = port.Port
+		}
+		if host != "" {
+			portMapping.Url = fmt.Sprintf("%s://%s:%d", portMapping.Protocol, host, externalPort)
+		}
+		portMappings = append(portMappings, portMapping)
+	}
+	return portMappings, nil
+}
+
+// requestedNodePort returns the node port a raw port is exposed as when it is inside the configured range, otherwise 0
+// so that the apiserver allocates one, which is free of races between concurrent deployments
+func requestedNodePort(requested int32) int32 {
+	min, max := int32(conf.GetConfig().Network.NodePortMin), int32(conf.GetConfig().Network.NodePortMax)
+	if min == 0 || max == 0 {
+		min, max = defaultNodePortMin, defaultNodePortMax
+	}
+	if requested >= min && requested <= max {
+		return requested
+	}
+	return 0
+}
+
+func hasRequestedNodePort(servicePorts []coreV1.ServicePort) bool {
+	for _, servicePort := range servicePorts {
+		if servicePort.NodePort != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func waitLoadBalancerAddress(namespace, serviceName string) string {
+	k8sService := NewK8sService()
+	for i := 0; i < 12; i++ {
+		service, err := k8sService.GetServiceByName(context.TODO(), namespace, serviceName, metaV1.GetOptions{})
+		if err == nil {
+			for _, ingress := range service.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" {
+					return ingress.IP
+				}
+				if ingress.Hostname != "" {
+					return ingress.Hostname
+				}
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+	logs.GetLogger().Warnf("Load balancer address of service %s is still pending", serviceName)
+	return ""
+}
+
+// getPublicIp returns the configured public IP, or the IP of API.MultiAddress
+func getPublicIp() string {
+	if publicIp := conf.GetConfig().Network.PublicIp; publicIp != "" {
+		return publicIp
+	}
+	parts := strings.Split(conf.GetConfig().API.MultiAddress, "/")
+	if len(parts) > 2 && (parts[1] == "ip4" || parts[1] == "ip6") {
+		return parts[2]
+	}
+	return ""
+}
@@ -0,0 +1,249 @@
+package computing
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/models"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	gpuCountPrefixRegexp = regexp.MustCompile(`^(\d+)\s*[xX×]\s+(.+)$`)
+	gpuCountSuffixRegexp = regexp.MustCompile(`^(.+?)\s+[xX×]\s*(\d+)$`)
+	gpuPartitionRegexp   = regexp.MustCompile(`^(.+?)\s*\((.+)\)$`)
+
+	hardwareCatalog     models.HardwareCatalog
+	hardwareCatalogOnce sync.Once
+)
+
+// getHardwareCatalog returns the accelerators of hardware_catalog.json, or the default catalog when there is none
+func getHardwareCatalog() models.HardwareCatalog {
+	hardwareCatalogOnce.Do(func() {
+		currentDir, _ := os.Getwd()
+		bytes, err := os.ReadFile(filepath.Join(currentDir, "hardware_catalog.json"))
+		if err != nil {
+			hardwareCatalog = defaultHardwareCatalog()
+			return
+		}
+		if err = json.Unmarshal(bytes, &hardwareCatalog); err != nil || len(hardwareCatalog.Accelerators) == 0 {
+			logs.GetLogger().Errorf("Failed load hardware_catalog.json, the default catalog is used, error: %v", err)
+			hardwareCatalog = defaultHardwareCatalog()
+		}
+	})
+	return hardwareCatalog
+}
+
+func defaultHardwareCatalog() models.HardwareCatalog {
+	return models.HardwareCatalog{
+		Accelerators: []models.Accelerator{
+			{
+				Vendor:          "NVIDIA",
+				Aliases:         []string{"Nvidia"},
+				Resource:        "nvidia.com/gpu",
+				PartitionPrefix: "nvidia.com/mig-",
+				SharedResource:  "nvidia.com/gpu.shared",
+			},
+			{
+				Vendor:   "AMD",
+				Aliases:  []string{"Amd"},
+				Resource: "amd.com/gpu",
+			},
+			{
+				Vendor:   "Intel",
+				Aliases:  []string{"INTEL"},
+				Resource: "gpu.intel.com/i915",
+			},
+		},
+	}
+}
+
+// findAccelerator returns the accelerator whose vendor the GPU model starts with, and the model named after
+// the vendor of the catalog. The first accelerator of the catalog is returned when no vendor matches.
+func findAccelerator(model string) (models.Accelerator, string) {
+	accelerators := getHardwareCatalog().Accelerators
+	for _, accelerator := range accelerators {
+		for _, name := range append([]string{accelerator.Vendor}, accelerator.Aliases...) {
+			if len(model) < len(name) || !strings.EqualFold(model[:len(name)], name) {
+				continue
+			}
+			rest := model[len(name):]
+			if rest == "" || rest[0] == ' ' || rest[0] == '-' {
+				return accelerator, accelerator.Vendor + rest
+			}
+		}
+	}
+	return accelerators[0], model
+}
+
+// normalizeGpuModel names the GPU model after its vendor in the catalog, e.g. Nvidia A100 is NVIDIA A100
+func normalizeGpuModel(model string) string {
+	_, model = findAccelerator(model)
+	return model
+}
+
+// parseGpuSpec parses the GPU part of a hardware description, e.g. "Nvidia A100", "2x Nvidia A100",
+// "Nvidia A100 x 2", "Nvidia A100 (MIG 1g.10gb)" or "Nvidia T4 (shared)", into the GPU model,
+// the number of GPUs and the extended resource they are requested with
+func parseGpuSpec(spec string) (model string, count int64, resourceName string) {
+	model, count = strings.TrimSpace(spec), 1
+
+	var partition string
+	if matches := gpuPartitionRegexp.FindStringSubmatch(model); matches != nil {
+		model, partition = strings.TrimSpace(matches[1]), strings.ToLower(strings.TrimSpace(matches[2]))
+	}
+	if matches := gpuCountPrefixRegexp.FindStringSubmatch(model); matches != nil {
+		count, _ = strconv.ParseInt(matches[1], 10, 64)
+		model = matches[2]
+	} else if matches := gpuCountSuffixRegexp.FindStringSubmatch(model); matches != nil {
+		count, _ = strconv.ParseInt(matches[2], 10, 64)
+		model = matches[1]
+	}
+	if count <= 0 {
+		count = 1
+	}
+
+	accelerator, model := findAccelerator(model)
+	resourceName = accelerator.Resource
+	switch {
+	case strings.HasPrefix(partition, "mig ") && accelerator.PartitionPrefix != "":
+		resourceName = accelerator.PartitionPrefix + strings.TrimSpace(strings.TrimPrefix(partition, "mig "))
+	case (partition == "shared" || partition == "time-sliced") && accelerator.SharedResource != "":
+		resourceName = accelerator.SharedResource
+	}
+	return model, count, resourceName
+}
+
+// gpuResourceName returns the extended resource the GPUs of the hardware are requested with
+func gpuResourceName(hardwareResource models.Resource) corev1.ResourceName {
+	if hardwareResource.GpuResource != "" {
+		return corev1.ResourceName(hardwareResource.GpuResource)
+	}
+	accelerator, _ := findAccelerator(hardwareResource.Gpu.Unit)
+	return corev1.ResourceName(accelerator.Resource)
+}
+
+// gpuNodeSelector selects the nodes with the GPU model of the hardware
+func gpuNodeSelector(hardwareResource models.Resource) map[string]string {
+	if hardwareResource.Gpu.Unit == "" {
+		return generateLabel("")
+	}
+	accelerator, _ := findAccelerator(hardwareResource.Gpu.Unit)
+	if accelerator.NodeLabel == "" {
+		return generateLabel(hardwareResource.Gpu.Unit)
+	}
+	return map[string]string{
+		accelerator.NodeLabel: strings.ReplaceAll(hardwareResource.Gpu.Unit, " ", "-"),
+	}
+}
+
+// gpuNameOfSelector returns the GPU model a node selector built by gpuNodeSelector selects
+func gpuNameOfSelector(key, value string) string {
+	for _, accelerator := range getHardwareCatalog().Accelerators {
+		if accelerator.NodeLabel != "" && accelerator.NodeLabel == key {
+			return value
+		}
+	}
+	return key
+}
+
+// gpuTaskKey returns the key the GPUs of a task being deployed are counted with in runTaskGpuResource:
+// the node label of the GPU model for whole GPUs, the extended resource for partitioned GPUs
+func gpuTaskKey(hardwareResource models.Resource) string {
+	if hardwareResource.Gpu.Unit == "" {
+		return ""
+	}
+	if resourceName := gpuResourceName(hardwareResource); isGpuPartitionResource(resourceName) {
+		return string(resourceName)
+	}
+	return strings.ReplaceAll(hardwareResource.Gpu.Unit, " ", "-")
+}
+
+// reserveTaskGpu counts GPUs of a task being deployed as used until the returned function is called
+func reserveTaskGpu(gpuName string, gpuCount int) func() {
+	if gpuName == "" || gpuCount <= 0 {
+		return func() {}
+	}
+	count, ok := runTaskGpuResource.Load(gpuName)
+	if ok {
+		runTaskGpuResource.Store(gpuName, count.(int)+gpuCount)
+	} else {
+		runTaskGpuResource.Store(gpuName, gpuCount)
+	}
+
+	return func() {
+		count, ok := runTaskGpuResource.Load(gpuName)
+		if ok && count.(int) > gpuCount {
+			runTaskGpuResource.Store(gpuName, count.(int)-gpuCount)
+		} else {
+			runTaskGpuResource.Delete(gpuName)
+		}
+	}
+}
+
+func isGpuResource(name corev1.ResourceName) bool {
+	for _, accelerator := range getHardwareCatalog().Accelerators {
+		if string(name) == accelerator.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+func isGpuPartitionResource(name corev1.ResourceName) bool {
+	for _, accelerator := range getHardwareCatalog().Accelerators {
+		if accelerator.PartitionPrefix != "" && strings.HasPrefix(string(name), accelerator.PartitionPrefix) {
+			return true
+		}
+		if accelerator.SharedResource != "" && string(name) == accelerator.SharedResource {
+			return true
+		}
+	}
+	return false
+}
+
+// gpuPartitionsOfNode returns the GPU partitions and time-sliced shares the node exposes, and how many of them are used
+func gpuPartitionsOfNode(pods []corev1.Pod, node *corev1.Node) []models.GpuPartition {
+	used := make(map[corev1.ResourceName]int64)
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			for name, val := range container.Resources.Requests {
+				if isGpuPartitionResource(name) {
+					used[name] += val.Value()
+				}
+			}
+		}
+	}
+
+	var partitions []models.GpuPartition
+	for name, val := range node.Status.Allocatable {
+		if !isGpuPartitionResource(name) || val.Value() == 0 {
+			continue
+		}
+		usedCount := used[name]
+		if num, ok := runTaskGpuResource.Load(string(name)); ok {
+			usedCount += int64(num.(int))
+		}
+		free := val.Value() - usedCount
+		if free < 0 {
+			free = 0
+		}
+		partitions = append(partitions, models.GpuPartition{
+			Resource: string(name),
+			Total:    val.Value(),
+			Used:     usedCount,
+			Free:     free,
+		})
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].Resource < partitions[j].Resource
+	})
+	return partitions
+}
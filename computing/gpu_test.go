@@ -0,0 +1,52 @@
+package computing
+
+import "testing"
+
+func TestParseGpuSpec(t *testing.T) {
+	tests := []struct {
+		spec         string
+		model        string
+		count        int64
+		resourceName string
+	}{
+		{spec: "Nvidia A100", model: "NVIDIA A100", count: 1, resourceName: "nvidia.com/gpu"},
+		{spec: "2x Nvidia A100", model: "NVIDIA A100", count: 2, resourceName: "nvidia.com/gpu"},
+		{spec: "NVIDIA A100 x 4", model: "NVIDIA A100", count: 4, resourceName: "nvidia.com/gpu"},
+		{spec: "0x NVIDIA A100", model: "NVIDIA A100", count: 1, resourceName: "nvidia.com/gpu"},
+		{spec: "Nvidia A100 (MIG 1g.10gb)", model: "NVIDIA A100", count: 1, resourceName: "nvidia.com/mig-1g.10gb"},
+		{spec: "2x Nvidia A100 (MIG 3g.20gb)", model: "NVIDIA A100", count: 2, resourceName: "nvidia.com/mig-3g.20gb"},
+		{spec: "Nvidia T4 (shared)", model: "NVIDIA T4", count: 1, resourceName: "nvidia.com/gpu.shared"},
+		{spec: "Nvidia T4 (time-sliced)", model: "NVIDIA T4", count: 1, resourceName: "nvidia.com/gpu.shared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			model, count, resourceName := parseGpuSpec(tt.spec)
+			if model != tt.model || count != tt.count || resourceName != tt.resourceName {
+				t.Fatalf("got %q, %d, %q, want %q, %d, %q", model, count, resourceName, tt.model, tt.count, tt.resourceName)
+			}
+		})
+	}
+}
+
+func TestFindAccelerator(t *testing.T) {
+	tests := []struct {
+		model    string
+		vendor   string
+		resolved string
+	}{
+		{model: "Nvidia A100", vendor: "NVIDIA", resolved: "NVIDIA A100"},
+		{model: "nvidia-tesla-t4", vendor: "NVIDIA", resolved: "NVIDIA-tesla-t4"},
+		{model: "Intel", vendor: "Intel", resolved: "Intel"},
+		// a vendor name must be followed by a separator, an unknown model falls back to the first accelerator
+		{model: "NvidiaX A100", vendor: "NVIDIA", resolved: "NvidiaX A100"},
+		{model: "Tesla T4", vendor: "NVIDIA", resolved: "Tesla T4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			accelerator, resolved := findAccelerator(tt.model)
+			if accelerator.Vendor != tt.vendor || resolved != tt.resolved {
+				t.Fatalf("got %s, %q, want %s, %q", accelerator.Vendor, resolved, tt.vendor, tt.resolved)
+			}
+		})
+	}
+}
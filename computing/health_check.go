@@ -0,0 +1,123 @@
+package computing
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const defaultReadyTimeout = 15 * time.Minute
+
+func getReadyTimeout() time.Duration {
+	if timeout := conf.GetConfig().Deploy.ReadyTimeout; timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return defaultReadyTimeout
+}
+
+// generateProbes builds the readiness, liveness and startup probes of a space container.
+// Without a health check, a tcp probe on the exposed port is used. The startup probe allows
+// the container to start for as long as the ready timeout before liveness checks begin.
+func generateProbes(containerPort int32, healthCheck *yaml.HealthCheck) (readiness, liveness, startup *coreV1.Probe) {
+	if containerPort == 0 && healthCheck == nil {
+		return nil, nil, nil
+	}
+
+	port := containerPort
+	readiness = &coreV1.Probe{
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      3,
+		FailureThreshold:    3,
+	}
+	handler := coreV1.ProbeHandler{}
+	probeType := "tcp"
+	if healthCheck != nil {
+		if healthCheck.Port != 0 {
+			port = int32(healthCheck.Port)
+		}
+		probeType = strings.ToLower(healthCheck.Type)
+		if probeType == "" && healthCheck.Path != "" {
+			probeType = "http"
+		}
+		if healthCheck.InitialDelay > 0 {
+			readiness.InitialDelaySeconds = int32(healthCheck.InitialDelay)
+		}
+		if healthCheck.Period > 0 {
+			readiness.PeriodSeconds = int32(healthCheck.Period)
+		}
+		if healthCheck.Timeout > 0 {
+			readiness.TimeoutSeconds = int32(healthCheck.Timeout)
+		}
+		if healthCheck.FailureThreshold > 0 {
+			readiness.FailureThreshold = int32(healthCheck.FailureThreshold)
+		}
+	}
+
+	switch probeType {
+	case "http":
+		path := healthCheck.Path
+		if path == "" {
+			path = "/"
+		}
+		handler.HTTPGet = &coreV1.HTTPGetAction{
+			Path: path,
+			Port: intstr.FromInt(int(port)),
+		}
+	case "exec":
+		if len(healthCheck.Command) == 0 {
+			return nil, nil, nil
+		}
+		handler.Exec = &coreV1.ExecAction{
+			Command: healthCheck.Command,
+		}
+	default:
+		if port == 0 {
+			return nil, nil, nil
+		}
+		handler.TCPSocket = &coreV1.TCPSocketAction{
+			Port: intstr.FromInt(int(port)),
+		}
+	}
+	readiness.ProbeHandler = handler
+
+	liveness = &coreV1.Probe{
+		ProbeHandler:     handler,
+		PeriodSeconds:    20,
+		TimeoutSeconds:   readiness.TimeoutSeconds,
+		FailureThreshold: 3,
+	}
+
+	startupThreshold := int32(getReadyTimeout().Seconds()) / readiness.PeriodSeconds
+	if startupThreshold < 1 {
+		startupThreshold = 1
+	}
+	startup = &coreV1.Probe{
+		ProbeHandler:        handler,
+		InitialDelaySeconds: readiness.InitialDelaySeconds,
+		PeriodSeconds:       readiness.PeriodSeconds,
+		TimeoutSeconds:      readiness.TimeoutSeconds,
+		FailureThreshold:    startupThreshold,
+	}
+	return readiness, liveness, startup
+}
+
+// dockerfileHealthCheck converts the HEALTHCHECK instruction of a Dockerfile to an exec health check
+func dockerfileHealthCheck(hc *docker.HealthCheck) *yaml.HealthCheck {
+	if hc == nil || len(hc.Command) == 0 {
+		return nil
+	}
+	return &yaml.HealthCheck{
+		Type:             "exec",
+		Command:          hc.Command,
+		InitialDelay:     int(hc.StartPeriod.Seconds()),
+		Period:           int(hc.Interval.Seconds()),
+		Timeout:          int(hc.Timeout.Seconds()),
+		FailureThreshold: hc.Retries,
+	}
+}
@@ -0,0 +1,75 @@
+package computing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lagrangedao/go-computing-provider/yaml"
+)
+
+func TestGenerateProbes(t *testing.T) {
+	tests := []struct {
+		name          string
+		containerPort int32
+		healthCheck   *yaml.HealthCheck
+		wantNil       bool
+		wantType      string
+		wantPort      int
+		wantPath      string
+		wantCommand   []string
+		wantPeriod    int32
+		wantStartup   int32
+	}{
+		{name: "no port and no health check", wantNil: true},
+		{name: "tcp on the exposed port", containerPort: 8080, wantType: "tcp", wantPort: 8080, wantPeriod: 10, wantStartup: 30},
+		{name: "http on the health check port", containerPort: 8080,
+			healthCheck: &yaml.HealthCheck{Path: "/healthz", Port: 9090, Period: 5},
+			wantType:    "http", wantPort: 9090, wantPath: "/healthz", wantPeriod: 5, wantStartup: 60},
+		{name: "http defaults to the root path", containerPort: 8080,
+			healthCheck: &yaml.HealthCheck{Type: "HTTP"},
+			wantType:    "http", wantPort: 8080, wantPath: "/", wantPeriod: 10, wantStartup: 30},
+		{name: "exec", healthCheck: &yaml.HealthCheck{Type: "exec", Command: []string{"pg_isready"}},
+			wantType: "exec", wantCommand: []string{"pg_isready"}, wantPeriod: 10, wantStartup: 30},
+		{name: "exec without a command", containerPort: 8080, healthCheck: &yaml.HealthCheck{Type: "exec"}, wantNil: true},
+		{name: "tcp without a port", healthCheck: &yaml.HealthCheck{Type: "tcp"}, wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness, liveness, startup := generateProbes(tt.containerPort, tt.healthCheck)
+			if tt.wantNil {
+				if readiness != nil || liveness != nil || startup != nil {
+					t.Fatalf("got probes %+v, want none", readiness)
+				}
+				return
+			}
+			if readiness == nil || liveness == nil || startup == nil {
+				t.Fatal("got no probes")
+			}
+
+			handler := readiness.ProbeHandler
+			switch tt.wantType {
+			case "tcp":
+				if handler.TCPSocket == nil || handler.TCPSocket.Port.IntValue() != tt.wantPort {
+					t.Fatalf("got handler %+v, want tcp on %d", handler, tt.wantPort)
+				}
+			case "http":
+				if handler.HTTPGet == nil || handler.HTTPGet.Port.IntValue() != tt.wantPort || handler.HTTPGet.Path != tt.wantPath {
+					t.Fatalf("got handler %+v, want http on %d%s", handler, tt.wantPort, tt.wantPath)
+				}
+			case "exec":
+				if handler.Exec == nil || !reflect.DeepEqual(handler.Exec.Command, tt.wantCommand) {
+					t.Fatalf("got handler %+v, want exec %v", handler, tt.wantCommand)
+				}
+			}
+			if !reflect.DeepEqual(liveness.ProbeHandler, handler) || !reflect.DeepEqual(startup.ProbeHandler, handler) {
+				t.Fatal("the liveness and startup probes must check the same as the readiness probe")
+			}
+			if readiness.PeriodSeconds != tt.wantPeriod {
+				t.Fatalf("got period %d, want %d", readiness.PeriodSeconds, tt.wantPeriod)
+			}
+			if startup.FailureThreshold != tt.wantStartup {
+				t.Fatalf("got startup threshold %d, want %d", startup.FailureThreshold, tt.wantStartup)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/docker"
+)
+
+const (
+	defaultHostPortMin = 30000
+	defaultHostPortMax = 32767
+)
+
+// hostPortAllocator hands out the host ports the docker orchestrator publishes raw global ports on. A port stays
+// reserved from its allocation until the container publishing it is started, so that concurrent deployments do not
+// pick the same one.
+type hostPortAllocator struct {
+	lock     sync.Mutex
+	reserved map[int32]bool
+}
+
+func newHostPortAllocator() *hostPortAllocator {
+	return &hostPortAllocator{reserved: make(map[int32]bool)}
+}
+
+// allocate returns the requested port when it is in Orchestrator.HostPortMin-HostPortMax and free, or else the first
+// free port of the range. The ports published by containers, 80, 443 and the port of the proxy are never allocated.
+func (a *hostPortAllocator) allocate(ctx context.Context, dockerService *docker.DockerService, requested int32) (int32, error) {
+	containers, err := dockerService.ListContainers(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed list the published ports, error: %w", err)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	used := map[int32]bool{80: true, 443: true, proxyPort(): true}
+	for _, c := range containers {
+		for _, port := range c.Ports {
+			used[int32(port.PublicPort)] = true
+		}
+	}
+	for port := range a.reserved {
+		used[port] = true
+	}
+
+	min, max := hostPortRange()
+	port, err := pickHostPort(requested, min, max, used)
+	if err != nil {
+		return 0, err
+	}
+	a.reserved[port] = true
+	return port, nil
+}
+
+// release frees the reservations of the ports once their container is started, or failed to
+func (a *hostPortAllocator) release(ports []docker.ContainerPort) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, port := range ports {
+		delete(a.reserved, port.HostPort)
+	}
+}
+
+// pickHostPort returns the requested port when it is in the range and not used, or else the first port of the range
+// not used
+func pickHostPort(requested, min, max int32, used map[int32]bool) (int32, error) {
+	if requested >= min && requested <= max && !used[requested] {
+		return requested, nil
+	}
+	for port := min; port <= max; port++ {
+		if !used[port] {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no host port is free in %d-%d", min, max)
+}
+
+func hostPortRange() (int32, int32) {
+	orchestratorConf := conf.GetConfig().Orchestrator
+	min, max := int32(orchestratorConf.HostPortMin), int32(orchestratorConf.HostPortMax)
+	if min <= 0 || max < min {
+		return defaultHostPortMin, defaultHostPortMax
+	}
+	return min, max
+}
+
+// proxyPort returns the port the space proxy listens on
+func proxyPort() int32 {
+	_, port, err := net.SplitHostPort(valueOrDefault(conf.GetConfig().Orchestrator.ProxyAddress, defaultProxyAddress))
+	if err != nil {
+		return 80
+	}
+	value, _ := strconv.Atoi(port)
+	return int32(value)
+}
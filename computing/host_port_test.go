@@ -0,0 +1,32 @@
+package computing
+
+import "testing"
+
+func TestPickHostPort(t *testing.T) {
+	used := map[int32]bool{80: true, 443: true, 30000: true, 30002: true}
+	tests := []struct {
+		name      string
+		requested int32
+		min, max  int32
+		want      int32
+		wantErr   bool
+	}{
+		{name: "free requested port", requested: 30001, min: 30000, max: 30010, want: 30001},
+		{name: "used requested port", requested: 30002, min: 30000, max: 30010, want: 30001},
+		{name: "requested port out of the range", requested: 22, min: 30000, max: 30010, want: 30001},
+		{name: "80 is never published", requested: 80, min: 1, max: 100, want: 1},
+		{name: "443 is never published", requested: 443, min: 443, max: 444, want: 444},
+		{name: "range exhausted", requested: 30000, min: 30000, max: 30000, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickHostPort(tt.requested, tt.min, tt.max, used)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got port %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package computing
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
@@ -9,29 +10,46 @@ import (
 	"github.com/lagrangedao/go-computing-provider/models"
 	"io"
 	"k8s.io/client-go/util/retry"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	appV1 "k8s.io/api/apps/v1"
+	autoscalingV2 "k8s.io/api/autoscaling/v2"
+	batchV1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
 
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/remotecommand"
 	"k8s.io/client-go/util/homedir"
 )
 
 var clientSet *kubernetes.Clientset
+var dynamicClient dynamic.Interface
+var restConfig *rest.Config
 var k8sOnce sync.Once
 
 type K8sService struct {
-	k8sClient *kubernetes.Clientset
-	Version   string
+	k8sClient     *kubernetes.Clientset
+	dynamicClient dynamic.Interface
+	config        *rest.Config
+	Version       string
 }
 
 func NewK8sService() *K8sService {
@@ -52,11 +70,17 @@ func NewK8sService() *K8sService {
 				return
 			}
 		}
+		restConfig = config
 		clientSet, err = kubernetes.NewForConfig(config)
 		if err != nil {
 			logs.GetLogger().Errorf("Failed create k8s clientset, error: %v", err)
 			return
 		}
+		dynamicClient, err = dynamic.NewForConfig(config)
+		if err != nil {
+			logs.GetLogger().Errorf("Failed create k8s dynamic client, error: %v", err)
+			return
+		}
 
 		versionInfo, err := clientSet.Discovery().ServerVersion()
 		if err != nil {
@@ -67,8 +91,10 @@ func NewK8sService() *K8sService {
 	})
 
 	return &K8sService{
-		k8sClient: clientSet,
-		Version:   version,
+		k8sClient:     clientSet,
+		dynamicClient: dynamicClient,
+		config:        restConfig,
+		Version:       version,
 	}
 }
 
@@ -109,8 +135,13 @@ func (s *K8sService) GetServiceByName(ctx context.Context, namespace, serviceNam
 	return s.k8sClient.CoreV1().Services(namespace).Get(ctx, serviceName, opts)
 }
 
-func (s *K8sService) CreateService(ctx context.Context, nameSpace, spaceUuid string, containerPort int32) (result *coreV1.Service, err error) {
-	service := &coreV1.Service{
+func (s *K8sService) CreateService(ctx context.Context, nameSpace, spaceUuid string, containerPorts []coreV1.ContainerPort, httpPorts []int32) (result *coreV1.Service, err error) {
+	return s.k8sClient.CoreV1().Services(nameSpace).Create(ctx, buildService(nameSpace, spaceUuid, containerPorts, httpPorts), metaV1.CreateOptions{})
+}
+
+// buildService builds the service of a workload, httpPorts are the ports the ingress routes to
+func buildService(nameSpace, spaceUuid string, containerPorts []coreV1.ContainerPort, httpPorts []int32) *coreV1.Service {
+	return &coreV1.Service{
 		TypeMeta: metaV1.TypeMeta{
 			Kind:       "Service",
 			APIVersion: "v1",
@@ -120,12 +151,128 @@ func (s *K8sService) CreateService(ctx context.Context, nameSpace, spaceUuid str
 			Namespace: nameSpace,
 		},
 		Spec: coreV1.ServiceSpec{
-			Ports: []coreV1.ServicePort{
-				{
-					Name: "http",
-					Port: containerPort,
-				},
+			Ports: containerServicePorts(containerPorts, httpPorts),
+			Selector: map[string]string{
+				"lad_app": spaceUuid,
 			},
+		},
+	}
+}
+
+// CreateDependService creates the service of a depends-on service of the space, labeled to be deleted with the space
+func (s *K8sService) CreateDependService(ctx context.Context, nameSpace, spaceUuid, dependName string, containerPorts []coreV1.ContainerPort) (*coreV1.Service, error) {
+	return s.k8sClient.CoreV1().Services(nameSpace).Create(ctx, buildDependService(nameSpace, spaceUuid, dependName, containerPorts), metaV1.CreateOptions{})
+}
+
+func buildDependService(nameSpace, spaceUuid, dependName string, containerPorts []coreV1.ContainerPort) *coreV1.Service {
+	return &coreV1.Service{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_SERVICE_NAME_PREFIX + dependName,
+			Namespace: nameSpace,
+			Labels:    map[string]string{"lad_space": spaceUuid},
+		},
+		Spec: coreV1.ServiceSpec{
+			Ports: containerServicePorts(containerPorts, nil),
+			Selector: map[string]string{
+				"lad_app": dependName,
+			},
+		},
+	}
+}
+
+// DeleteDependResources deletes the deployments and services of the depends-on services of the space
+func (s *K8sService) DeleteDependResources(ctx context.Context, namespace, spaceUuid string) error {
+	listOptions := metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_space=%s", spaceUuid),
+	}
+	services, err := s.k8sClient.CoreV1().Services(namespace).List(ctx, listOptions)
+	if err != nil {
+		return err
+	}
+	for _, service := range services.Items {
+		if err = s.DeleteService(ctx, namespace, service.Name); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	propagationPolicy := metaV1.DeletePropagationBackground
+	return s.k8sClient.AppsV1().Deployments(namespace).DeleteCollection(ctx, metaV1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	}, listOptions)
+}
+
+// ListSpaceWorkloads returns the names of the workloads whose deployments belong to the space
+func (s *K8sService) ListSpaceWorkloads(ctx context.Context, namespace, spaceUuid string) ([]string, error) {
+	deployments, err := s.k8sClient.AppsV1().Deployments(namespace).List(ctx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_space_svc=%s", spaceUuid),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var workloads []string
+	for _, deployment := range deployments.Items {
+		workloads = append(workloads, strings.TrimPrefix(deployment.Name, constants.K8S_DEPLOY_NAME_PREFIX))
+	}
+
+	jobs, err := s.k8sClient.BatchV1().Jobs(namespace).List(ctx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_space_svc=%s", spaceUuid),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs.Items {
+		workloads = append(workloads, strings.TrimPrefix(job.Name, constants.K8S_JOB_NAME_PREFIX))
+	}
+	return workloads, nil
+}
+
+// containerServicePorts names the service ports after their protocol and number, the first of httpPorts is named http
+// and the other ports the ingress routes to http-<port>, so that ingress controllers and meshes detect their protocol
+func containerServicePorts(containerPorts []coreV1.ContainerPort, httpPorts []int32) []coreV1.ServicePort {
+	var servicePorts []coreV1.ServicePort
+	for _, containerPort := range containerPorts {
+		protocol := containerPort.Protocol
+		if protocol == "" {
+			protocol = coreV1.ProtocolTCP
+		}
+		name := fmt.Sprintf("%s-%d", strings.ToLower(string(protocol)), containerPort.ContainerPort)
+		for i, httpPort := range httpPorts {
+			if protocol != coreV1.ProtocolTCP || httpPort != containerPort.ContainerPort {
+				continue
+			}
+			name = fmt.Sprintf("http-%d", httpPort)
+			if i == 0 {
+				name = "http"
+			}
+		}
+		servicePorts = append(servicePorts, coreV1.ServicePort{
+			Name:       name,
+			Port:       containerPort.ContainerPort,
+			TargetPort: intstr.FromInt(int(containerPort.ContainerPort)),
+			Protocol:   protocol,
+		})
+	}
+	return servicePorts
+}
+
+// CreateExternalService publishes raw ports of the space outside the cluster with a NodePort or LoadBalancer service
+func (s *K8sService) CreateExternalService(ctx context.Context, nameSpace, spaceUuid string, serviceType coreV1.ServiceType, servicePorts []coreV1.ServicePort) (*coreV1.Service, error) {
+	service := &coreV1.Service{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_SERVICE_NAME_PREFIX + spaceUuid + constants.K8S_EXTERNAL_SERVICE_SUFFIX,
+			Namespace: nameSpace,
+		},
+		Spec: coreV1.ServiceSpec{
+			Type:  serviceType,
+			Ports: servicePorts,
 			Selector: map[string]string{
 				"lad_app": spaceUuid,
 			},
@@ -138,44 +285,224 @@ func (s *K8sService) DeleteService(ctx context.Context, namespace, serviceName s
 	return s.k8sClient.CoreV1().Services(namespace).Delete(ctx, serviceName, metaV1.DeleteOptions{})
 }
 
-func (s *K8sService) CreateIngress(ctx context.Context, k8sNameSpace, spaceUuid, hostName string, port int32) (*networkingv1.Ingress, error) {
+// ingressRoute routes a host name to a port of the space service
+type ingressRoute struct {
+	Host string
+	Port int32
+}
+
+// ingressTLS is the certificate secret serving the hosts of an ingress, issued by cert-manager when ClusterIssuer is set,
+// the default certificate of the ingress controller serves them when SecretName is empty
+type ingressTLS struct {
+	SecretName    string
+	ClusterIssuer string
+}
+
+func (s *K8sService) CreateIngress(ctx context.Context, k8sNameSpace, spaceUuid string, routes []ingressRoute, tls *ingressTLS) (*networkingv1.Ingress, error) {
+	return s.k8sClient.NetworkingV1().Ingresses(k8sNameSpace).Create(ctx, buildIngress(k8sNameSpace, spaceUuid, routes, tls), metaV1.CreateOptions{})
+}
+
+func buildIngress(k8sNameSpace, spaceUuid string, routes []ingressRoute, tls *ingressTLS) *networkingv1.Ingress {
 	var ingressClassName = "nginx"
+	var rules []networkingv1.IngressRule
+	var hosts []string
+	for _, route := range routes {
+		hosts = append(hosts, route.Host)
+		rules = append(rules, networkingv1.IngressRule{
+			Host: route.Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{
+							Path:     "/*",
+							PathType: func() *networkingv1.PathType { t := networkingv1.PathTypePrefix; return &t }(),
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: constants.K8S_SERVICE_NAME_PREFIX + spaceUuid,
+									Port: networkingv1.ServiceBackendPort{
+										Number: route.Port,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
 	ingress := &networkingv1.Ingress{
 		ObjectMeta: metaV1.ObjectMeta{
-			Name: constants.K8S_INGRESS_NAME_PREFIX + spaceUuid,
+			Name:      constants.K8S_INGRESS_NAME_PREFIX + spaceUuid,
+			Namespace: k8sNameSpace,
 			Annotations: map[string]string{
 				"nginx.ingress.kubernetes.io/use-regex": "true",
 			},
 		},
 		Spec: networkingv1.IngressSpec{
 			IngressClassName: &ingressClassName,
-			Rules: []networkingv1.IngressRule{
-				{
-					Host: hostName,
-					IngressRuleValue: networkingv1.IngressRuleValue{
-						HTTP: &networkingv1.HTTPIngressRuleValue{
-							Paths: []networkingv1.HTTPIngressPath{
-								{
-									Path:     "/*",
-									PathType: func() *networkingv1.PathType { t := networkingv1.PathTypePrefix; return &t }(),
-									Backend: networkingv1.IngressBackend{
-										Service: &networkingv1.IngressServiceBackend{
-											Name: constants.K8S_SERVICE_NAME_PREFIX + spaceUuid,
-											Port: networkingv1.ServiceBackendPort{
-												Number: port,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
+			Rules:            rules,
+		},
+	}
+	if tls != nil {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      hosts,
+				SecretName: tls.SecretName,
+			},
+		}
+		ingress.Annotations["nginx.ingress.kubernetes.io/ssl-redirect"] = "true"
+		if tls.ClusterIssuer != "" {
+			ingress.Annotations["cert-manager.io/cluster-issuer"] = tls.ClusterIssuer
+		}
+	}
+	return ingress
+}
+
+// CreateSecret creates the secret, or replaces its data when it already exists
+func (s *K8sService) CreateSecret(ctx context.Context, namespace string, secret *coreV1.Secret) (*coreV1.Secret, error) {
+	created, err := s.k8sClient.CoreV1().Secrets(namespace).Create(ctx, secret, metaV1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return created, err
+	}
+
+	var updated *coreV1.Secret
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := s.k8sClient.CoreV1().Secrets(namespace).Get(ctx, secret.Name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		existing.Data = nil
+		existing.StringData = secret.StringData
+		updated, err = s.k8sClient.CoreV1().Secrets(namespace).Update(ctx, existing, metaV1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+func (s *K8sService) DeleteSecret(ctx context.Context, namespace, secretName string) error {
+	return s.k8sClient.CoreV1().Secrets(namespace).Delete(ctx, secretName, metaV1.DeleteOptions{})
+}
+
+// DeleteSecrets deletes the secrets of the namespace whose name matches
+func (s *K8sService) DeleteSecrets(ctx context.Context, namespace string, match func(name string) bool) error {
+	secrets, err := s.k8sClient.CoreV1().Secrets(namespace).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets.Items {
+		if !match(secret.Name) {
+			continue
+		}
+		if err = s.DeleteSecret(ctx, namespace, secret.Name); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddIngressHost routes an additional host to the backend of the first rule of the ingress,
+// served with the certificate in tlsSecretName when it is set
+func (s *K8sService) AddIngressHost(ctx context.Context, nameSpace, ingressName, host, tlsSecretName string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ingress, err := s.k8sClient.NetworkingV1().Ingresses(nameSpace).Get(ctx, ingressName, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if len(ingress.Spec.Rules) == 0 {
+			return fmt.Errorf("ingress %s has no rule to route %s to", ingressName, host)
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host == host {
+				return nil
+			}
+		}
+
+		rule := *ingress.Spec.Rules[0].DeepCopy()
+		rule.Host = host
+		ingress.Spec.Rules = append(ingress.Spec.Rules, rule)
+		if tlsSecretName != "" {
+			ingress.Spec.TLS = append(ingress.Spec.TLS, networkingv1.IngressTLS{
+				Hosts:      []string{host},
+				SecretName: tlsSecretName,
+			})
+		}
+		_, err = s.k8sClient.NetworkingV1().Ingresses(nameSpace).Update(ctx, ingress, metaV1.UpdateOptions{})
+		return err
+	})
+}
+
+// RemoveIngressHost removes the rule and the certificate of a host from the ingress
+func (s *K8sService) RemoveIngressHost(ctx context.Context, nameSpace, ingressName, host string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ingress, err := s.k8sClient.NetworkingV1().Ingresses(nameSpace).Get(ctx, ingressName, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		var rules []networkingv1.IngressRule
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host != host {
+				rules = append(rules, rule)
+			}
+		}
+		var tls []networkingv1.IngressTLS
+		for _, t := range ingress.Spec.TLS {
+			if len(t.Hosts) != 1 || t.Hosts[0] != host {
+				tls = append(tls, t)
+			}
+		}
+		ingress.Spec.Rules = rules
+		ingress.Spec.TLS = tls
+		_, err = s.k8sClient.NetworkingV1().Ingresses(nameSpace).Update(ctx, ingress, metaV1.UpdateOptions{})
+		return err
+	})
+}
+
+var certificateResource = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+
+// CreateCertificate requests a cert-manager certificate for dnsNames, stored in secretName once issued
+func (s *K8sService) CreateCertificate(ctx context.Context, nameSpace, name, secretName, clusterIssuer string, dnsNames []string) error {
+	var names []interface{}
+	for _, dnsName := range dnsNames {
+		names = append(names, dnsName)
+	}
+	certificate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": nameSpace,
+			},
+			"spec": map[string]interface{}{
+				"secretName": secretName,
+				"dnsNames":   names,
+				"issuerRef": map[string]interface{}{
+					"name": clusterIssuer,
+					"kind": "ClusterIssuer",
 				},
 			},
 		},
 	}
+	_, err := s.dynamicClient.Resource(certificateResource).Namespace(nameSpace).Create(ctx, certificate, metaV1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
 
-	return s.k8sClient.NetworkingV1().Ingresses(k8sNameSpace).Create(ctx, ingress, metaV1.CreateOptions{})
+// DeleteCertificate deletes a cert-manager certificate and the secret it was stored in
+func (s *K8sService) DeleteCertificate(ctx context.Context, nameSpace, name, secretName string) error {
+	err := s.dynamicClient.Resource(certificateResource).Namespace(nameSpace).Delete(ctx, name, metaV1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	err = s.k8sClient.CoreV1().Secrets(nameSpace).Delete(ctx, secretName, metaV1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
 }
 
 func (s *K8sService) DeleteIngress(ctx context.Context, nameSpace, ingressName string) error {
@@ -183,6 +510,14 @@ func (s *K8sService) DeleteIngress(ctx context.Context, nameSpace, ingressName s
 }
 
 func (s *K8sService) CreateConfigMap(ctx context.Context, k8sNameSpace, spaceUuid, basePath, configName string) (*coreV1.ConfigMap, error) {
+	configMap, err := buildConfigMap(k8sNameSpace, spaceUuid, basePath, configName)
+	if err != nil {
+		return nil, err
+	}
+	return s.k8sClient.CoreV1().ConfigMaps(k8sNameSpace).Create(ctx, configMap, metaV1.CreateOptions{})
+}
+
+func buildConfigMap(k8sNameSpace, spaceUuid, basePath, configName string) (*coreV1.ConfigMap, error) {
 	configFilePath := filepath.Join(basePath, configName)
 
 	fileNameWithoutExt := filepath.Base(configName[:len(configName)-len(filepath.Ext(configName))])
@@ -192,15 +527,82 @@ func (s *K8sService) CreateConfigMap(ctx context.Context, k8sNameSpace, spaceUui
 		return nil, err
 	}
 
-	configMap := &coreV1.ConfigMap{
+	return &coreV1.ConfigMap{
 		ObjectMeta: metaV1.ObjectMeta{
-			Name: spaceUuid + "-" + fileNameWithoutExt,
+			Name:      spaceUuid + "-" + fileNameWithoutExt,
+			Namespace: k8sNameSpace,
 		},
 		Data: map[string]string{
 			configName: string(iniData),
 		},
+	}, nil
+}
+
+// EnsurePersistentVolumeClaim returns the volume claim of the space, creating it if it does not exist.
+// A claim which was released at the end of a lease is kept again.
+func (s *K8sService) EnsurePersistentVolumeClaim(ctx context.Context, namespace, spaceUuid, storageClass string, size resource.Quantity) (*coreV1.PersistentVolumeClaim, error) {
+	pvcName := constants.K8S_PVC_NAME_PREFIX + spaceUuid
+	pvc, err := s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metaV1.GetOptions{})
+	if err == nil {
+		if _, ok := pvc.Annotations[constants.K8S_PVC_RELEASE_ANNOTATION]; ok {
+			delete(pvc.Annotations, constants.K8S_PVC_RELEASE_ANNOTATION)
+			return s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, pvc, metaV1.UpdateOptions{})
+		}
+		return pvc, nil
 	}
-	return s.k8sClient.CoreV1().ConfigMaps(k8sNameSpace).Create(ctx, configMap, metaV1.CreateOptions{})
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	pvc = &coreV1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"lad_app": spaceUuid,
+			},
+		},
+		Spec: coreV1.PersistentVolumeClaimSpec{
+			AccessModes: []coreV1.PersistentVolumeAccessMode{coreV1.ReadWriteOnce},
+			Resources: coreV1.ResourceRequirements{
+				Requests: coreV1.ResourceList{
+					coreV1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+	if storageClass != "" {
+		pvc.Spec.StorageClassName = &storageClass
+	}
+	return s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metaV1.CreateOptions{})
+}
+
+// ReleasePersistentVolumeClaim marks the volume claim of the space to be deleted after releaseAt
+func (s *K8sService) ReleasePersistentVolumeClaim(ctx context.Context, namespace, spaceUuid string, releaseAt time.Time) error {
+	pvc, err := s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, constants.K8S_PVC_NAME_PREFIX+spaceUuid, metaV1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string)
+	}
+	pvc.Annotations[constants.K8S_PVC_RELEASE_ANNOTATION] = strconv.FormatInt(releaseAt.Unix(), 10)
+	_, err = s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, pvc, metaV1.UpdateOptions{})
+	return err
+}
+
+func (s *K8sService) ListPersistentVolumeClaims(ctx context.Context, namespace string) ([]coreV1.PersistentVolumeClaim, error) {
+	list, err := s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metaV1.ListOptions{
+		LabelSelector: "lad_app",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (s *K8sService) DeletePersistentVolumeClaim(ctx context.Context, namespace, pvcName string) error {
+	return s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, pvcName, metaV1.DeleteOptions{})
 }
 
 func (s *K8sService) GetPods(namespace, spaceUuid string) (bool, error) {
@@ -221,31 +623,350 @@ func (s *K8sService) GetPods(namespace, spaceUuid string) (bool, error) {
 	return false, nil
 }
 
-func (s *K8sService) CreateNetworkPolicy(ctx context.Context, namespace string) (*networkingv1.NetworkPolicy, error) {
-	networkPolicy := &networkingv1.NetworkPolicy{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:      namespace + "-" + generateString(4),
-			Namespace: namespace,
-		},
-		Spec: networkingv1.NetworkPolicySpec{
-			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
-			Ingress: []networkingv1.NetworkPolicyIngressRule{
-				{
-					From: []networkingv1.NetworkPolicyPeer{
-						{
-							NamespaceSelector: &metaV1.LabelSelector{
-								MatchLabels: map[string]string{
-									"kubernetes.io/metadata.name": "ingress-nginx",
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+// WaitForPodReady blocks until a pod of the space is ready. It returns an error describing why the pod
+// could not become ready when a non-recoverable state is detected or the context is done.
+func (s *K8sService) WaitForPodReady(ctx context.Context, namespace, spaceUuid string) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	reason := "pod was not created"
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("space did not become ready in time, reason: %s", reason)
+		case <-ticker.C:
+			podList, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{
+				LabelSelector: fmt.Sprintf("lad_app=%s", spaceUuid),
+			})
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get pods, namespace: %s, spaceUuid: %s, error: %+v", namespace, spaceUuid, err)
+				continue
+			}
+			if len(podList.Items) == 0 {
+				if createReason := s.podCreateFailureReason(ctx, namespace, spaceUuid); createReason != "" {
+					return fmt.Errorf("space failed to start, reason: %s", createReason)
+				}
+			}
+			for _, pod := range podList.Items {
+				if pod.DeletionTimestamp != nil {
+					continue
+				}
+				if isPodReady(&pod) {
+					return nil
+				}
+				if podReason, fatal := podFailureReason(&pod); podReason != "" {
+					reason = podReason
+					if fatal {
+						return fmt.Errorf("space failed to start, reason: %s", reason)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *K8sService) CreateBatchJob(ctx context.Context, namespace string, job *batchV1.Job) (*batchV1.Job, error) {
+	return s.k8sClient.BatchV1().Jobs(namespace).Create(ctx, job, metaV1.CreateOptions{})
+}
+
+func (s *K8sService) DeleteBatchJob(ctx context.Context, namespace, name string) error {
+	propagationPolicy := metaV1.DeletePropagationBackground
+	return s.k8sClient.BatchV1().Jobs(namespace).Delete(ctx, name, metaV1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+}
+
+// WaitForJobComplete waits until the Kubernetes Job succeeded, and returns the name of the pod which ran it
+func (s *K8sService) WaitForJobComplete(ctx context.Context, namespace, jobName string) (string, error) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var podName, reason string
+	for {
+		select {
+		case <-ctx.Done():
+			return podName, fmt.Errorf("job did not complete in time")
+		case <-ticker.C:
+			podList, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{
+				LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+			})
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get pods, namespace: %s, jobName: %s, error: %+v", namespace, jobName, err)
+				continue
+			}
+			for _, pod := range podList.Items {
+				podName = pod.Name
+				statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+				for _, status := range statuses {
+					if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+						reason = fmt.Sprintf("container %s exited with code %d %s %s", status.Name, terminated.ExitCode, terminated.Reason, terminated.Message)
+					}
+				}
+				if podReason, _ := podFailureReason(&pod); podReason != "" {
+					reason = podReason
+				}
+			}
+
+			job, err := s.k8sClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metaV1.GetOptions{})
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get job, namespace: %s, jobName: %s, error: %+v", namespace, jobName, err)
+				continue
+			}
+			if job.Status.Succeeded > 0 {
+				return podName, nil
+			}
+			for _, condition := range job.Status.Conditions {
+				if condition.Type == batchV1.JobFailed && condition.Status == coreV1.ConditionTrue {
+					if reason == "" {
+						reason = condition.Message
+					}
+					return podName, fmt.Errorf("job failed, %s: %s", condition.Reason, reason)
+				}
+			}
+		}
+	}
+}
+
+// WaitForJobContainer waits until the container of the pod of the job is running, or has exited successfully when
+// exited is set, and returns the name of the pod. It fails as soon as the container or the pod failed for good, a pod
+// waiting to be scheduled or for its image is waited for until ctx is done.
+func (s *K8sService) WaitForJobContainer(ctx context.Context, namespace, jobName, containerName string, exited bool) (string, error) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	reason := "pod was not created"
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("container %s of job %s did not start in time, reason: %s", containerName, jobName, reason)
+		case <-ticker.C:
+			podList, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{
+				LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+			})
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get pods, namespace: %s, jobName: %s, error: %+v", namespace, jobName, err)
+				continue
+			}
+			for _, pod := range podList.Items {
+				if podReason, fatal := podFailureReason(&pod); podReason != "" {
+					reason = podReason
+					if fatal {
+						return pod.Name, fmt.Errorf("pod %s failed, %s", pod.Name, reason)
+					}
+				}
+				for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+					if status.Name != containerName {
+						continue
+					}
+					if terminated := status.State.Terminated; terminated != nil {
+						if terminated.ExitCode != 0 {
+							return pod.Name, fmt.Errorf("container %s exited with code %d %s %s", status.Name, terminated.ExitCode, terminated.Reason, terminated.Message)
+						}
+						if exited {
+							return pod.Name, nil
+						}
+					}
+					if status.State.Running != nil && !exited {
+						return pod.Name, nil
+					}
+				}
+			}
+		}
+	}
+}
+
+// ExecWithStdin runs the command in the container of the pod with stdin as its standard input, the standard error
+// of the command is returned along the error when it fails
+func (s *K8sService) ExecWithStdin(namespace, podName, containerName string, command []string, stdin io.Reader) error {
+	req := s.k8sClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&coreV1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(s.config, http.MethodPost, req.URL())
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	if err = executor.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: io.Discard, Stderr: &stderr}); err != nil {
+		return fmt.Errorf("%w %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// WaitForBatchOutput waits until the main container of the batch job completed and the collector container serves its output,
+// and returns the name of the pod serving it
+func (s *K8sService) WaitForBatchOutput(ctx context.Context, namespace, jobName, collectorName string) (string, error) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var reason string
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("batch job did not complete in time")
+		case <-ticker.C:
+			job, err := s.k8sClient.BatchV1().Jobs(namespace).Get(ctx, jobName, metaV1.GetOptions{})
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get batch job, namespace: %s, jobName: %s, error: %+v", namespace, jobName, err)
+				continue
+			}
+			for _, condition := range job.Status.Conditions {
+				if condition.Type == batchV1.JobFailed && condition.Status == coreV1.ConditionTrue {
+					if reason == "" {
+						reason = condition.Message
+					}
+					return "", fmt.Errorf("batch job failed, %s: %s", condition.Reason, reason)
+				}
+			}
+
+			podList, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{
+				LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+			})
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get pods, namespace: %s, jobName: %s, error: %+v", namespace, jobName, err)
+				continue
+			}
+			for _, pod := range podList.Items {
+				for _, status := range pod.Status.InitContainerStatuses {
+					if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+						reason = fmt.Sprintf("container %s exited with code %d %s %s", status.Name, terminated.ExitCode, terminated.Reason, terminated.Message)
+					}
+				}
+				if podReason, _ := podFailureReason(&pod); podReason != "" {
+					reason = podReason
+				}
+				for _, status := range pod.Status.ContainerStatuses {
+					if status.Name == collectorName && status.Ready {
+						return pod.Name, nil
+					}
+				}
+			}
+		}
+	}
+}
+
+// GetPodFile streams a file served over HTTP by a pod, through the proxy of the API server
+func (s *K8sService) GetPodFile(ctx context.Context, namespace, podName string, port int, path string) (io.ReadCloser, error) {
+	return s.k8sClient.CoreV1().Pods(namespace).ProxyGet("http", podName, strconv.Itoa(port), path, nil).Stream(ctx)
+}
+
+// CreateHorizontalPodAutoscaler creates the autoscaler, or replaces its spec when it already exists
+func (s *K8sService) CreateHorizontalPodAutoscaler(ctx context.Context, namespace string, hpa *autoscalingV2.HorizontalPodAutoscaler) (*autoscalingV2.HorizontalPodAutoscaler, error) {
+	created, err := s.k8sClient.AutoscalingV2().HorizontalPodAutoscalers(namespace).Create(ctx, hpa, metaV1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return created, err
+	}
+
+	var updated *autoscalingV2.HorizontalPodAutoscaler
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := s.k8sClient.AutoscalingV2().HorizontalPodAutoscalers(namespace).Get(ctx, hpa.Name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		existing.Spec = hpa.Spec
+		updated, err = s.k8sClient.AutoscalingV2().HorizontalPodAutoscalers(namespace).Update(ctx, existing, metaV1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+func (s *K8sService) DeleteHorizontalPodAutoscaler(ctx context.Context, namespace, name string) error {
+	return s.k8sClient.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(ctx, name, metaV1.DeleteOptions{})
+}
+
+// CreateResourceQuota creates the resource quota, or replaces its spec when it already exists
+func (s *K8sService) CreateResourceQuota(ctx context.Context, namespace string, resourceQuota *coreV1.ResourceQuota) (*coreV1.ResourceQuota, error) {
+	created, err := s.k8sClient.CoreV1().ResourceQuotas(namespace).Create(ctx, resourceQuota, metaV1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return created, err
+	}
+
+	var updated *coreV1.ResourceQuota
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := s.k8sClient.CoreV1().ResourceQuotas(namespace).Get(ctx, resourceQuota.Name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		existing.Spec = resourceQuota.Spec
+		updated, err = s.k8sClient.CoreV1().ResourceQuotas(namespace).Update(ctx, existing, metaV1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+// CreateLimitRange creates the limit range, or replaces its spec when it already exists
+func (s *K8sService) CreateLimitRange(ctx context.Context, namespace string, limitRange *coreV1.LimitRange) (*coreV1.LimitRange, error) {
+	created, err := s.k8sClient.CoreV1().LimitRanges(namespace).Create(ctx, limitRange, metaV1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return created, err
+	}
+
+	var updated *coreV1.LimitRange
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := s.k8sClient.CoreV1().LimitRanges(namespace).Get(ctx, limitRange.Name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		existing.Spec = limitRange.Spec
+		updated, err = s.k8sClient.CoreV1().LimitRanges(namespace).Update(ctx, existing, metaV1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+// CreateNetworkPolicy creates the network policy, or replaces its spec when it already exists
+func (s *K8sService) CreateNetworkPolicy(ctx context.Context, namespace string, networkPolicy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
+	created, err := s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Create(ctx, networkPolicy, metaV1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return created, err
 	}
 
-	return s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Create(ctx, networkPolicy, metaV1.CreateOptions{})
+	var updated *networkingv1.NetworkPolicy
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Get(ctx, networkPolicy.Name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		existing.Spec = networkPolicy.Spec
+		updated, err = s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Update(ctx, existing, metaV1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+func (s *K8sService) DeleteNetworkPolicy(ctx context.Context, namespace, name string) error {
+	return s.k8sClient.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metaV1.DeleteOptions{})
+}
+
+// GetClusterApiIps returns the IPs the cluster API server is reachable at from pods
+func (s *K8sService) GetClusterApiIps(ctx context.Context) ([]string, error) {
+	var ips []string
+	service, err := s.k8sClient.CoreV1().Services(metaV1.NamespaceDefault).Get(ctx, "kubernetes", metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if service.Spec.ClusterIP != "" && service.Spec.ClusterIP != coreV1.ClusterIPNone {
+		ips = append(ips, service.Spec.ClusterIP)
+	}
+
+	endpoints, err := s.k8sClient.CoreV1().Endpoints(metaV1.NamespaceDefault).Get(ctx, "kubernetes", metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			ips = append(ips, address.IP)
+		}
+	}
+	return ips, nil
 }
 
 func (s *K8sService) CreateNameSpace(ctx context.Context, nameSpace *coreV1.Namespace, opts metaV1.CreateOptions) (result *coreV1.Namespace, err error) {
@@ -256,6 +977,25 @@ func (s *K8sService) GetNameSpace(ctx context.Context, nameSpace string, opts me
 	return s.k8sClient.CoreV1().Namespaces().Get(ctx, nameSpace, opts)
 }
 
+// UpdateNameSpaceLabels sets the labels on the namespace, keeping its other labels
+func (s *K8sService) UpdateNameSpaceLabels(ctx context.Context, nameSpace string, labels map[string]string) (result *coreV1.Namespace, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		namespace, err := s.k8sClient.CoreV1().Namespaces().Get(ctx, nameSpace, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if namespace.Labels == nil {
+			namespace.Labels = map[string]string{}
+		}
+		for key, value := range labels {
+			namespace.Labels[key] = value
+		}
+		result, err = s.k8sClient.CoreV1().Namespaces().Update(ctx, namespace, metaV1.UpdateOptions{})
+		return err
+	})
+	return result, err
+}
+
 func (s *K8sService) DeleteNameSpace(ctx context.Context, nameSpace string) error {
 	return s.k8sClient.CoreV1().Namespaces().Delete(ctx, nameSpace, metaV1.DeleteOptions{})
 }
@@ -354,7 +1094,7 @@ func (s *K8sService) StatisticalSources(ctx context.Context) ([]*models.NodeReso
 				gpuName := strings.ReplaceAll(gpuDetail.ProductName, " ", "-")
 				newDetail := gpuDetail
 				g := collectGpu[gpuName]
-				if g.remainNum > 0 && counter[gpuName] < g.remainNum {
+				if g.remainNum > 0 && counter[gpuName] < g.remainNum && nodeUsableByTier(&node, gpuDetail.ProductName) {
 					newDetail.Status = models.Available
 					counter[gpuName] += 1
 				} else {
@@ -369,6 +1109,7 @@ func (s *K8sService) StatisticalSources(ctx context.Context) ([]*models.NodeReso
 				Details:       newGpu,
 			}
 		}
+		nodeResource.Gpu.Partitions = gpuPartitionsOfNode(getPodsFromNode(activePods, &node), &node)
 		nodeList = append(nodeList, nodeResource)
 	}
 	return nodeList, nil
@@ -402,6 +1143,30 @@ func (s *K8sService) GetPodLog(ctx context.Context) (map[string]*strings.Builder
 	return result, nil
 }
 
+// ListWorkloadPods returns the pods of a workload of a space
+func (s *K8sService) ListWorkloadPods(ctx context.Context, namespace, workload string) ([]coreV1.Pod, error) {
+	podList, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_app=%s", workload),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// GetContainerLog returns the last lines a container of the pod wrote
+func (s *K8sService) GetContainerLog(ctx context.Context, namespace, podName, containerName string, tailLines int64) (string, error) {
+	req := s.k8sClient.CoreV1().Pods(namespace).GetLogs(podName, &coreV1.PodLogOptions{
+		Container: containerName,
+		TailLines: &tailLines,
+	})
+	buf, err := readLog(req)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 func (s *K8sService) AddNodeLabel(nodeName, key string) error {
 	key = strings.ReplaceAll(key, " ", "-")
 
@@ -434,6 +1199,70 @@ func readLog(req *rest.Request) (*strings.Builder, error) {
 	return buf, nil
 }
 
+func isPodReady(pod *coreV1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreV1.PodReady {
+			return condition.Status == coreV1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podCreateFailureReason returns why the pods of the space could not be created, e.g. the wallet quota is exceeded
+func (s *K8sService) podCreateFailureReason(ctx context.Context, namespace, spaceUuid string) string {
+	rsList, err := s.k8sClient.AppsV1().ReplicaSets(namespace).List(ctx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("lad_app=%s", spaceUuid),
+	})
+	if err != nil {
+		return ""
+	}
+	for _, rs := range rsList.Items {
+		for _, condition := range rs.Status.Conditions {
+			if condition.Type != appV1.ReplicaSetReplicaFailure || condition.Status != coreV1.ConditionTrue {
+				continue
+			}
+			if strings.Contains(condition.Message, "exceeded quota") {
+				return "the resources of the wallet quota are exhausted, " + condition.Message
+			}
+			if strings.Contains(condition.Message, "forbidden") {
+				return condition.Message
+			}
+		}
+	}
+	return ""
+}
+
+// podFailureReason returns why the pod is not ready yet, and whether the pod can not recover from it
+func podFailureReason(pod *coreV1.Pod) (string, bool) {
+	if pod.Status.Phase == coreV1.PodFailed {
+		return fmt.Sprintf("pod failed: %s %s", pod.Status.Reason, pod.Status.Message), true
+	}
+
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if waiting := status.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case "InvalidImageName", "ErrImageNeverPull", "CreateContainerConfigError", "CreateContainerError":
+				return fmt.Sprintf("container %s: %s %s", status.Name, waiting.Reason, waiting.Message), true
+			case "CrashLoopBackOff":
+				reason := fmt.Sprintf("container %s: %s", status.Name, waiting.Reason)
+				if terminated := status.LastTerminationState.Terminated; terminated != nil {
+					reason = fmt.Sprintf("%s, exit code: %d %s", reason, terminated.ExitCode, terminated.Message)
+				}
+				return reason, status.RestartCount >= 3
+			case "ErrImagePull", "ImagePullBackOff":
+				return fmt.Sprintf("container %s: %s %s", status.Name, waiting.Reason, waiting.Message), false
+			}
+		}
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreV1.PodScheduled && condition.Status == coreV1.ConditionFalse {
+			return fmt.Sprintf("%s: %s", condition.Reason, condition.Message), false
+		}
+	}
+	return "", false
+}
+
 func generateLabel(name string) map[string]string {
 	if name != "" {
 		key := strings.ReplaceAll(name, " ", "-")
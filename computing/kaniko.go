@@ -0,0 +1,280 @@
+package computing
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	builderKaniko         = "kaniko"
+	defaultBuildNamespace = "lagrange-build"
+	defaultKanikoImage    = "gcr.io/kaniko-project/executor:v1.9.2"
+	defaultFetchImage     = "busybox:1.36"
+	defaultPushImage      = "gcr.io/go-containerregistry/crane:debug"
+	kanikoContainerName   = "kaniko"
+	fetchContainerName    = "fetch"
+	pushContainerName     = "push"
+	kanikoWorkspace       = "/workspace"
+	kanikoOutput          = "/output"
+	pushCredentialPath    = "/push"
+	kanikoLogTailLines    = 5000
+	buildContextVolume    = "build-context"
+	buildOutputVolume     = "build-output"
+	pushCredentialVolume  = "push-credential"
+	contextReceivedFile   = "/tmp/received"
+)
+
+// isKanikoBuilder tells whether the images of spaces are built inside the cluster instead of by the Docker daemon of the host
+func isKanikoBuilder() bool {
+	return strings.EqualFold(conf.GetConfig().Build.Builder, builderKaniko)
+}
+
+// buildImageInCluster builds the image of a space with Kaniko as a Kubernetes Job. The build context is streamed into
+// the pod of the job from the downloaded space files, Kaniko builds the image without any registry credential, then
+// the push container pushes it with a credential scoped to the repository of the image, only created once the build
+// is done and deleted with the job. The logs of the build pod are written to out.
+func buildImageInCluster(jobUuid, imagePath, imageName string, out io.Writer) (err error) {
+	if conf.GetConfig().Registry.ServerAddress == "" {
+		return fmt.Errorf("the kaniko builder requires Registry.ServerAddress to push images to")
+	}
+	if isDockerOrchestrator() {
+		return fmt.Errorf("the kaniko builder requires the kubernetes orchestrator")
+	}
+
+	cpu, memory, err := buildResources()
+	if err != nil {
+		return err
+	}
+	contextSize, err := resource.ParseQuantity(valueOrDefault(conf.GetConfig().Build.MaxContextSize, defaultBuildContextSize))
+	if err != nil {
+		return fmt.Errorf("invalid Build.MaxContextSize %s, error: %w", conf.GetConfig().Build.MaxContextSize, err)
+	}
+
+	namespace := valueOrDefault(conf.GetConfig().Build.Namespace, defaultBuildNamespace)
+	k8sService := NewK8sService()
+	if err = ensureBuildNamespace(k8sService, namespace); err != nil {
+		return err
+	}
+
+	job := kanikoJob(namespace, constants.K8S_BUILD_NAME_PREFIX+jobUuid, imageName, coreV1.ResourceList{
+		coreV1.ResourceCPU:    cpu,
+		coreV1.ResourceMemory: memory,
+	}, contextSize)
+	if _, err = k8sService.CreateBatchJob(context.TODO(), namespace, job); err != nil {
+		return fmt.Errorf("failed create build job, error: %w", err)
+	}
+	logs.GetLogger().Infof("Created build job: %s, image: %s", job.Name, imageName)
+	defer func() {
+		if err := k8sService.DeleteBatchJob(context.TODO(), namespace, job.Name); err != nil && !errors.IsNotFound(err) {
+			logs.GetLogger().Errorf("Failed delete build job, jobName: %s, error: %+v", job.Name, err)
+		}
+		if err := k8sService.DeleteSecret(context.TODO(), namespace, job.Name); err != nil && !errors.IsNotFound(err) {
+			logs.GetLogger().Errorf("Failed delete push credential, jobName: %s, error: %+v", job.Name, err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout())
+	defer cancel()
+	podName, err := k8sService.WaitForJobContainer(ctx, namespace, job.Name, fetchContainerName, false)
+	if err == nil {
+		err = sendBuildContext(k8sService, namespace, podName, imagePath)
+	}
+	if err == nil {
+		_, err = k8sService.WaitForJobContainer(ctx, namespace, job.Name, kanikoContainerName, true)
+	}
+	if err == nil {
+		err = createPushCredential(k8sService, namespace, job.Name, imageName)
+	}
+	if err == nil {
+		podName, err = k8sService.WaitForJobComplete(ctx, namespace, job.Name)
+	}
+	if podName != "" {
+		writeBuildPodLog(k8sService, namespace, podName, out)
+	}
+	if err != nil {
+		logs.GetLogger().Errorf("Build job %s failed, error: %v", job.Name, err)
+		return fmt.Errorf("failed build image, %w", buildFailureReason(ctx, err))
+	}
+	logs.GetLogger().Infof("Built and pushed image %s", imageName)
+	return nil
+}
+
+// writeBuildPodLog writes the logs of the containers fetching the build context, building and pushing the image to out
+func writeBuildPodLog(k8sService *K8sService, namespace, podName string, out io.Writer) {
+	for _, containerName := range []string{fetchContainerName, kanikoContainerName, pushContainerName} {
+		podLog, err := k8sService.GetContainerLog(context.TODO(), namespace, podName, containerName, kanikoLogTailLines)
+		if err != nil {
+			logs.GetLogger().Warnf("Failed get the log of the build pod, pod: %s, container: %s, error: %v", podName, containerName, err)
+			continue
+		}
+		io.WriteString(out, podLog)
+	}
+}
+
+// sendBuildContext streams the build context as a tar.gz into the workspace of the fetch container of the build pod,
+// the context never leaves the provider and the cluster
+func sendBuildContext(k8sService *K8sService, namespace, podName, imagePath string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		gw := gzip.NewWriter(writer)
+		err := docker.ArchiveBuildContext(imagePath, gw)
+		if err == nil {
+			err = gw.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	defer reader.Close()
+
+	command := []string{"sh", "-c", fmt.Sprintf("tar xzf - -C %s && touch %s", kanikoWorkspace, contextReceivedFile)}
+	if err := k8sService.ExecWithStdin(namespace, podName, fetchContainerName, command, reader); err != nil {
+		return fmt.Errorf("failed send the build context, error: %w", err)
+	}
+	return nil
+}
+
+// createPushCredential creates the secret the push container waits for, holding the docker config of the registry
+// limited to the repository of the image when the registry supports it
+func createPushCredential(k8sService *K8sService, namespace, jobName, imageName string) error {
+	auth, err := docker.RepositoryPushAuth(imageName)
+	if err != nil {
+		return err
+	}
+	dockerConfig, _ := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			conf.GetConfig().Registry.ServerAddress: auth,
+		},
+	})
+	_, err = k8sService.CreateSecret(context.TODO(), namespace, &coreV1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      jobName,
+			Namespace: namespace,
+		},
+		StringData: map[string]string{"config.json": string(dockerConfig)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed create push credential, error: %w", err)
+	}
+	return nil
+}
+
+// ensureBuildNamespace creates the namespace of the build jobs, isolated like the namespaces of wallets when
+// NetworkPolicy is enabled: nothing reaches the build pods, and they only reach DNS and the addresses outside the cluster
+func ensureBuildNamespace(k8sService *K8sService, namespace string) error {
+	if _, err := k8sService.GetNameSpace(context.TODO(), namespace, metaV1.GetOptions{}); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if _, err = k8sService.CreateNameSpace(context.TODO(), &coreV1.Namespace{
+			ObjectMeta: metaV1.ObjectMeta{Name: namespace},
+		}, metaV1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed create namespace of build jobs, error: %w", err)
+		}
+	}
+	// the registry credential of the whole namespace is replaced by a credential per job
+	if err := k8sService.DeleteSecret(context.TODO(), namespace, constants.K8S_REGISTRY_SECRET_NAME); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed delete registry secret of build jobs, error: %w", err)
+	}
+	return applyBuildNetworkPolicies(k8sService, namespace)
+}
+
+// kanikoJob builds the Job whose fetch container receives the build context into the workspace, then Kaniko builds the
+// image into a tarball within the resource limits, and the push container pushes it once its credential is created
+func kanikoJob(namespace, jobName, imageName string, limits coreV1.ResourceList, contextSize resource.Quantity) *batchV1.Job {
+	buildConf := conf.GetConfig().Build
+	imageTar := kanikoOutput + "/image.tar"
+	args := []string{
+		"--context=dir://" + kanikoWorkspace,
+		"--dockerfile=Dockerfile",
+		"--destination=" + imageName,
+		"--no-push",
+		"--tar-path=" + imageTar,
+	}
+	pushCmd := fmt.Sprintf(`until [ -f %s/config.json ]; do sleep 1; done; crane push`, pushCredentialPath)
+	if buildConf.InsecureRegistry {
+		args = append(args, "--insecure", "--skip-tls-verify")
+		pushCmd += " --insecure"
+	}
+	pushCmd += ` "$IMAGE_TAR" "$IMAGE_NAME"`
+
+	backoffLimit := int32(0)
+	activeDeadline := int64(buildTimeout() / time.Second)
+	automountToken := false
+	credentialOptional := true
+	workspaceMount := coreV1.VolumeMount{Name: buildContextVolume, MountPath: kanikoWorkspace}
+	outputMount := coreV1.VolumeMount{Name: buildOutputVolume, MountPath: kanikoOutput}
+	return &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      jobName,
+			Namespace: namespace,
+		},
+		Spec: batchV1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &activeDeadline,
+			Template: coreV1.PodTemplateSpec{
+				Spec: coreV1.PodSpec{
+					RestartPolicy:                coreV1.RestartPolicyNever,
+					AutomountServiceAccountToken: &automountToken,
+					InitContainers: []coreV1.Container{
+						{
+							Name:            fetchContainerName,
+							Image:           valueOrDefault(buildConf.FetchImage, defaultFetchImage),
+							ImagePullPolicy: coreV1.PullIfNotPresent,
+							Command:         []string{"sh", "-c", fmt.Sprintf("until [ -f %s ]; do sleep 1; done", contextReceivedFile)},
+							VolumeMounts:    []coreV1.VolumeMount{workspaceMount},
+						},
+						{
+							Name:                     kanikoContainerName,
+							Image:                    valueOrDefault(buildConf.KanikoImage, defaultKanikoImage),
+							ImagePullPolicy:          coreV1.PullIfNotPresent,
+							Args:                     args,
+							TerminationMessagePolicy: coreV1.TerminationMessageFallbackToLogsOnError,
+							Resources:                coreV1.ResourceRequirements{Limits: limits},
+							VolumeMounts:             []coreV1.VolumeMount{workspaceMount, outputMount},
+						},
+					},
+					Containers: []coreV1.Container{{
+						Name:            pushContainerName,
+						Image:           valueOrDefault(buildConf.PushImage, defaultPushImage),
+						ImagePullPolicy: coreV1.PullIfNotPresent,
+						Command:         []string{"sh", "-c", pushCmd},
+						Env: []coreV1.EnvVar{
+							{Name: "DOCKER_CONFIG", Value: pushCredentialPath},
+							{Name: "IMAGE_TAR", Value: imageTar},
+							{Name: "IMAGE_NAME", Value: imageName},
+						},
+						TerminationMessagePolicy: coreV1.TerminationMessageFallbackToLogsOnError,
+						VolumeMounts: []coreV1.VolumeMount{
+							outputMount,
+							{Name: pushCredentialVolume, MountPath: pushCredentialPath, ReadOnly: true},
+						},
+					}},
+					Volumes: []coreV1.Volume{
+						{Name: buildContextVolume, VolumeSource: coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{SizeLimit: &contextSize}}},
+						{Name: buildOutputVolume, VolumeSource: coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{}}},
+						{Name: pushCredentialVolume, VolumeSource: coreV1.VolumeSource{
+							Secret: &coreV1.SecretVolumeSource{
+								SecretName: jobName,
+								Optional:   &credentialOptional,
+							},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package computing
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+)
+
+// testConfig is the config.toml the tests of the package run with
+const testConfig = `
+[API]
+MultiAddress = "/ip4/127.0.0.1/tcp/8085"
+Domain = "example.com"
+RedisUrl = "redis://127.0.0.1:6379"
+
+[LAG]
+ServerUrl = "https://api.lagrangedao.org"
+AccessToken = "token"
+
+[MCS]
+ApiKey = "key"
+AccessToken = "token"
+BucketName = "bucket"
+Network = "polygon.mumbai"
+FileCachePath = "/tmp"
+
+[Registry]
+ServerAddress = ""
+
+[Deploy]
+ReadyTimeout = 300
+MaxReplicas = 4
+
+[Deploy.TierMaxReplicas]
+GPU = 2
+"NVIDIA A100" = 1
+
+[Placement.GPU]
+NodeSelector = { "lad/pool" = "gpu" }
+Tolerations = [{ Key = "nvidia.com/gpu", Operator = "Exists", Effect = "NoSchedule" }]
+
+[Placement."NVIDIA A100"]
+NodeAffinity = [{ Key = "lad/zone", Operator = "In", Values = ["a", "b"] }]
+`
+
+func TestMain(m *testing.M) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	configDir, err := os.MkdirTemp("", "computing-test-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(testConfig), 0644); err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Chdir(configDir); err != nil {
+		log.Fatal(err)
+	}
+	if err = conf.InitConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Chdir(currentDir); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(configDir)
+	os.Exit(code)
+}
@@ -0,0 +1,349 @@
+package computing
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The builders below only describe the resources of a space, they are shared by the deployment of a space
+// and by its dry-run rendering so that both produce the same manifests.
+
+func buildNamespace(creatorWallet string) *coreV1.Namespace {
+	return &coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: constants.K8S_NAMESPACE_NAME_PREFIX + creatorWallet,
+			Labels: map[string]string{
+				"lab-ns": creatorWallet,
+			},
+		},
+	}
+}
+
+// hardenPodSpec applies the security profile, the runtime class and the placement of the hardware tier to the pod
+func hardenPodSpec(podSpec *coreV1.PodSpec, creatorWallet, spaceUuid string, hardwareResource models.Resource) {
+	applySecurityProfile(podSpec)
+	applyRuntimeClass(podSpec, creatorWallet, hardwareResource)
+	applyPlacement(podSpec, spaceUuid, hardwareResource)
+}
+
+// hardwareRequirements returns the resources of the container running the space on the hardware of the order
+func hardwareRequirements(hardwareResource models.Resource) (coreV1.ResourceRequirements, error) {
+	memQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardwareResource.Memory.Quantity, hardwareResource.Memory.Unit))
+	if err != nil {
+		return coreV1.ResourceRequirements{}, fmt.Errorf("get memory failed, error: %w", err)
+	}
+
+	storageQuantity, err := resource.ParseQuantity(fmt.Sprintf("%d%s", hardwareResource.Storage.Quantity, hardwareResource.Storage.Unit))
+	if err != nil {
+		return coreV1.ResourceRequirements{}, fmt.Errorf("get storage failed, error: %w", err)
+	}
+
+	return coreV1.ResourceRequirements{
+		Limits: coreV1.ResourceList{
+			coreV1.ResourceCPU:                *resource.NewQuantity(hardwareResource.Cpu.Quantity, resource.DecimalSI),
+			coreV1.ResourceMemory:             memQuantity,
+			coreV1.ResourceEphemeralStorage:   storageQuantity,
+			gpuResourceName(hardwareResource): *resource.NewQuantity(hardwareResource.Gpu.Quantity, resource.DecimalSI),
+		},
+		Requests: coreV1.ResourceList{
+			coreV1.ResourceCPU:                *resource.NewQuantity(hardwareResource.Cpu.Quantity, resource.DecimalSI),
+			coreV1.ResourceMemory:             memQuantity,
+			coreV1.ResourceEphemeralStorage:   storageQuantity,
+			gpuResourceName(hardwareResource): *resource.NewQuantity(hardwareResource.Gpu.Quantity, resource.DecimalSI),
+		},
+	}, nil
+}
+
+// splitOrder splits the CPU, memory and storage of the order evenly between the containers running in a space, the
+// primary service also gets the GPUs and what is left of the division. A space of a single container gets the order.
+func splitOrder(hardwareResource models.Resource, containers int) (primary, share coreV1.ResourceRequirements, err error) {
+	order, err := hardwareRequirements(hardwareResource)
+	if err != nil || containers <= 1 {
+		return order, order, err
+	}
+
+	count := int64(containers)
+	primaryList, shareList := coreV1.ResourceList{}, coreV1.ResourceList{}
+	for name, quantity := range order.Limits {
+		switch name {
+		case coreV1.ResourceCPU:
+			milliCpu := quantity.MilliValue()
+			shareList[name] = *resource.NewMilliQuantity(milliCpu/count, resource.DecimalSI)
+			primaryList[name] = *resource.NewMilliQuantity(milliCpu-milliCpu/count*(count-1), resource.DecimalSI)
+		case coreV1.ResourceMemory, coreV1.ResourceEphemeralStorage:
+			bytes := quantity.Value()
+			shareList[name] = *resource.NewQuantity(bytes/count, resource.BinarySI)
+			primaryList[name] = *resource.NewQuantity(bytes-bytes/count*(count-1), resource.BinarySI)
+		default:
+			primaryList[name] = quantity
+		}
+	}
+	primary = coreV1.ResourceRequirements{Limits: primaryList, Requests: primaryList.DeepCopy()}
+	share = coreV1.ResourceRequirements{Limits: shareList, Requests: shareList.DeepCopy()}
+	return primary, share, nil
+}
+
+// spaceContainers counts the containers running along in a space: its services, their sidecars and their depends-on
+// services with the service role, init containers run before the others and are not counted
+func spaceContainers(containerResources []yaml.ContainerResource) int {
+	var containers int
+	for _, cr := range containerResources {
+		containers++
+		for _, depend := range cr.Depends {
+			if role, _ := dependRole(depend); role != dependRoleInit {
+				containers++
+			}
+		}
+	}
+	return containers
+}
+
+// spaceEnv returns the env vars every space is started with
+func spaceEnv(creatorWallet, spaceUuid, hostName, jobUuid string) []coreV1.EnvVar {
+	return []coreV1.EnvVar{
+		{
+			Name:  "wallet_address",
+			Value: creatorWallet,
+		},
+		{
+			Name:  "space_uuid",
+			Value: spaceUuid,
+		},
+		{
+			Name:  "result_url",
+			Value: hostName,
+		},
+		{
+			Name:  "job_uuid",
+			Value: jobUuid,
+		},
+	}
+}
+
+// serviceEnv returns the env vars of a service of deploy.yaml followed by the ones of every space,
+// NEXTAUTH_URL is pointed at the host name of the space
+func serviceEnv(creatorWallet, spaceUuid, hostName, jobUuid string, env []coreV1.EnvVar) []coreV1.EnvVar {
+	for i, envVar := range env {
+		if strings.Contains(envVar.Name, "NEXTAUTH_URL") {
+			env[i].Value = "https://" + hostName
+			break
+		}
+	}
+	return append(env, spaceEnv(creatorWallet, spaceUuid, hostName, jobUuid)...)
+}
+
+// spaceDeployment builds the deployment of a workload of the space running the pod template
+func spaceDeployment(k8sNameSpace, spaceUuid, workloadName string, replicas int32, strategy appV1.DeploymentStrategy, template coreV1.PodTemplateSpec) *appV1.Deployment {
+	return &appV1.Deployment{
+		TypeMeta: metaV1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_DEPLOY_NAME_PREFIX + workloadName,
+			Namespace: k8sNameSpace,
+			Labels:    map[string]string{"lad_space_svc": spaceUuid},
+		},
+		Spec: appV1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metaV1.LabelSelector{
+				MatchLabels: map[string]string{"lad_app": workloadName},
+			},
+			Strategy: strategy,
+			Template: template,
+		},
+	}
+}
+
+// configMapVolume returns the volume of the config file of a service and where it is mounted
+func configMapVolume(spaceUuid string, configMap *coreV1.ConfigMap, configFile yaml.ConfigFile) ([]coreV1.Volume, []coreV1.VolumeMount) {
+	fileNameWithoutExt := filepath.Base(configFile.Name[:len(configFile.Name)-len(filepath.Ext(configFile.Name))])
+	volumes := []coreV1.Volume{
+		{
+			Name: spaceUuid + "-" + fileNameWithoutExt,
+			VolumeSource: coreV1.VolumeSource{
+				ConfigMap: &coreV1.ConfigMapVolumeSource{
+					LocalObjectReference: coreV1.LocalObjectReference{
+						Name: configMap.GetName(),
+					},
+				},
+			},
+		},
+	}
+	volumeMounts := []coreV1.VolumeMount{
+		{
+			Name:      spaceUuid + "-" + fileNameWithoutExt,
+			MountPath: configFile.Path,
+		},
+	}
+	return volumes, volumeMounts
+}
+
+// servicePodTemplate builds the pod of a workload running a service of deploy.yaml after its init containers and
+// along its sidecars, every container runs within its share of the order
+func servicePodTemplate(jobUuid, creatorWallet, k8sNameSpace, spaceUuid, hostName string, workload spaceWorkload, cr yaml.ContainerResource,
+	volumes []coreV1.Volume, volumeMounts []coreV1.VolumeMount, hostAliases []coreV1.HostAlias) (coreV1.PodTemplateSpec, error) {
+	initContainers, containers, err := dependContainers(spaceUuid, cr.Depends, workload.DependResources)
+	if err != nil {
+		return coreV1.PodTemplateSpec{}, err
+	}
+
+	cr.Env = serviceEnv(creatorWallet, spaceUuid, hostName, jobUuid, cr.Env)
+
+	readinessProbe, livenessProbe, startupProbe := generateProbes(firstContainerPort(cr.Ports), cr.HealthCheck)
+	containers = append(containers, coreV1.Container{
+		Name:            spaceUuid + "-" + cr.Name,
+		Image:           cr.ImageName,
+		Command:         cr.Command,
+		Args:            cr.Args,
+		Env:             cr.Env,
+		Ports:           cr.Ports,
+		ImagePullPolicy: coreV1.PullIfNotPresent,
+		ReadinessProbe:  readinessProbe,
+		LivenessProbe:   livenessProbe,
+		StartupProbe:    startupProbe,
+		Resources:       *workload.Resources.DeepCopy(),
+		VolumeMounts:    volumeMounts,
+	})
+
+	return coreV1.PodTemplateSpec{
+		ObjectMeta: metaV1.ObjectMeta{
+			Labels:    map[string]string{"lad_app": workload.Name},
+			Namespace: k8sNameSpace,
+		},
+		Spec: coreV1.PodSpec{
+			NodeSelector:   gpuNodeSelector(workload.Hardware),
+			InitContainers: initContainers,
+			Containers:     containers,
+			Volumes:        volumes,
+			HostAliases:    hostAliases,
+		},
+	}, nil
+}
+
+// dockerfileResource describes the space built from a Dockerfile as a service: its exposed ports are routed
+// through the ingress, its health check probes it and its first volume is persisted
+func dockerfileResource(imageName, dockerfilePath string) (yaml.ContainerResource, error) {
+	cr := yaml.ContainerResource{ImageName: imageName}
+	exposedPorts, err := docker.ExtractExposedPorts(dockerfilePath)
+	if err != nil {
+		return cr, fmt.Errorf("failed to extract exposed port: %w", err)
+	}
+	for _, exposedPort := range exposedPorts {
+		containerPort := coreV1.ContainerPort{
+			ContainerPort: int32(exposedPort.Port),
+			Protocol:      coreV1.Protocol(strings.ToUpper(exposedPort.Protocol)),
+		}
+		cr.Ports = append(cr.Ports, containerPort)
+		cr.Exposes = append(cr.Exposes, yaml.PortExpose{
+			Port:     containerPort.ContainerPort,
+			Protocol: containerPort.Protocol,
+			Http:     containerPort.Protocol != coreV1.ProtocolUDP,
+			Global:   true,
+		})
+	}
+
+	healthCheck, err := docker.ExtractHealthCheck(dockerfilePath)
+	if err != nil {
+		return cr, fmt.Errorf("failed to extract health check: %w", err)
+	}
+	cr.HealthCheck = dockerfileHealthCheck(healthCheck)
+
+	dockerVolumes, err := docker.ExtractVolumes(dockerfilePath)
+	if err != nil {
+		return cr, fmt.Errorf("failed to extract volumes: %w", err)
+	}
+	if len(dockerVolumes) > 0 {
+		cr.PersistentStorage = &yaml.PersistentStorage{Paths: dockerVolumes}
+	}
+	return cr, nil
+}
+
+// dockerfilePodTemplate builds the pod running the image of a space built from a Dockerfile
+func dockerfilePodTemplate(jobUuid, hostName, creatorWallet, k8sNameSpace, spaceUuid string, cr yaml.ContainerResource, hardwareResource models.Resource,
+	volumes []coreV1.Volume, volumeMounts []coreV1.VolumeMount) (coreV1.PodTemplateSpec, error) {
+	resources, err := hardwareRequirements(hardwareResource)
+	if err != nil {
+		return coreV1.PodTemplateSpec{}, err
+	}
+
+	readinessProbe, livenessProbe, startupProbe := generateProbes(firstContainerPort(cr.Ports), cr.HealthCheck)
+	return coreV1.PodTemplateSpec{
+		ObjectMeta: metaV1.ObjectMeta{
+			Labels:    map[string]string{"lad_app": spaceUuid},
+			Namespace: k8sNameSpace,
+		},
+		Spec: coreV1.PodSpec{
+			NodeSelector: gpuNodeSelector(hardwareResource),
+			Volumes:      volumes,
+			Containers: []coreV1.Container{{
+				Name:            constants.K8S_CONTAINER_NAME_PREFIX + spaceUuid,
+				Image:           cr.ImageName,
+				ImagePullPolicy: coreV1.PullIfNotPresent,
+				Ports:           cr.Ports,
+				ReadinessProbe:  readinessProbe,
+				LivenessProbe:   livenessProbe,
+				StartupProbe:    startupProbe,
+				Env:             spaceEnv(creatorWallet, spaceUuid, hostName, jobUuid),
+				Resources:       resources,
+				VolumeMounts:    volumeMounts,
+			}},
+		},
+	}, nil
+}
+
+// exposedContainerPorts returns the container ports the service of a workload forwards to
+func exposedContainerPorts(exposes []yaml.PortExpose) []coreV1.ContainerPort {
+	var containerPorts []coreV1.ContainerPort
+	for _, expose := range exposes {
+		containerPorts = append(containerPorts, coreV1.ContainerPort{
+			ContainerPort: expose.Port,
+			Protocol:      expose.Protocol,
+		})
+	}
+	return containerPorts
+}
+
+// httpPorts returns the ports of a workload the ingress routes to, in the order of their routes
+func httpPorts(exposes []yaml.PortExpose) []int32 {
+	var ports []int32
+	for _, expose := range exposes {
+		if expose.Http {
+			ports = append(ports, expose.Port)
+		}
+	}
+	return ports
+}
+
+// ingressRoutes returns the routes of the http ports of a workload, the first one is served at the host name
+// and the others at <prefix>-<port>.<domain>, with the endpoint every http port is reachable at
+func ingressRoutes(hostName string, exposes []yaml.PortExpose) ([]ingressRoute, []models.PortMapping) {
+	var routes []ingressRoute
+	var portMappings []models.PortMapping
+	for _, expose := range exposes {
+		if !expose.Http {
+			continue
+		}
+		host := hostName
+		if len(routes) > 0 {
+			host = portHostName(hostName, expose.Port)
+		}
+		routes = append(routes, ingressRoute{Host: host, Port: expose.Port})
+		portMappings = append(portMappings, models.PortMapping{
+			ContainerPort: expose.Port,
+			Protocol:      "http",
+			Url:           "https://" + host,
+		})
+	}
+	return routes, portMappings
+}
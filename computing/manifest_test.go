@@ -0,0 +1,91 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestSplitOrder(t *testing.T) {
+	hardware := models.Resource{
+		Cpu:         models.Specification{Quantity: 2},
+		Memory:      models.Specification{Quantity: 4, Unit: "Gi"},
+		Storage:     models.Specification{Quantity: 30, Unit: "Gi"},
+		Gpu:         models.Specification{Quantity: 1, Unit: "NVIDIA A100"},
+		GpuResource: "nvidia.com/gpu",
+	}
+
+	tests := []struct {
+		name                 string
+		containers           int
+		primaryCpu, shareCpu string
+		primaryMem, shareMem string
+		primaryGpu, shareGpu bool
+	}{
+		{name: "single container gets the order", containers: 1, primaryCpu: "2", shareCpu: "2",
+			primaryMem: "4Gi", shareMem: "4Gi", primaryGpu: true, shareGpu: true},
+		{name: "two containers", containers: 2, primaryCpu: "1", shareCpu: "1",
+			primaryMem: "2Gi", shareMem: "2Gi", primaryGpu: true},
+		{name: "the remainder goes to the primary", containers: 3, primaryCpu: "668m", shareCpu: "666m",
+			primaryMem: "1431655766", shareMem: "1431655765", primaryGpu: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, share, err := splitOrder(hardware, tt.containers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, requirements := range []coreV1.ResourceRequirements{primary, share} {
+				if len(requirements.Limits) == 0 || !equalResourceLists(requirements.Limits, requirements.Requests) {
+					t.Fatalf("the limits %v must be set and equal the requests %v", requirements.Limits, requirements.Requests)
+				}
+			}
+			checkQuantity(t, "primary cpu", primary.Limits[coreV1.ResourceCPU], tt.primaryCpu)
+			checkQuantity(t, "share cpu", share.Limits[coreV1.ResourceCPU], tt.shareCpu)
+			checkQuantity(t, "primary memory", primary.Limits[coreV1.ResourceMemory], tt.primaryMem)
+			checkQuantity(t, "share memory", share.Limits[coreV1.ResourceMemory], tt.shareMem)
+			if _, ok := primary.Limits["nvidia.com/gpu"]; ok != tt.primaryGpu {
+				t.Fatalf("primary gpu: got %v, want %v", ok, tt.primaryGpu)
+			}
+			if _, ok := share.Limits["nvidia.com/gpu"]; ok != tt.shareGpu {
+				t.Fatalf("share gpu: got %v, want %v", ok, tt.shareGpu)
+			}
+		})
+	}
+}
+
+func TestSpaceContainers(t *testing.T) {
+	containerResources := []yaml.ContainerResource{
+		{Name: "web", Depends: []yaml.ContainerResource{
+			{Name: "migrate", Role: "init"},
+			{Name: "proxy"},
+			{Name: "db", Role: "service"},
+		}},
+		{Name: "worker"},
+	}
+	if got := spaceContainers(containerResources); got != 4 {
+		t.Fatalf("got %d containers, want 4", got)
+	}
+}
+
+func checkQuantity(t *testing.T, name string, got resource.Quantity, want string) {
+	t.Helper()
+	if got.Cmp(resource.MustParse(want)) != 0 {
+		t.Fatalf("%s: got %s, want %s", name, got.String(), want)
+	}
+}
+
+func equalResourceLists(a, b coreV1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		if other, ok := b[name]; !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
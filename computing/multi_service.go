@@ -0,0 +1,108 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// spaceWorkload is how a service of the space is deployed. The primary service is named after the space, gets its host
+// name and the GPUs of the order; every other service of a multi-service space is named <spaceUuid>-<service> and its
+// global http ports are routed at <prefix>-<service>.<domain>. Every service and depends-on service runs within its
+// share of the order.
+type spaceWorkload struct {
+	Name            string
+	HostName        string
+	Hardware        models.Resource
+	Primary         bool
+	Resources       coreV1.ResourceRequirements
+	DependResources coreV1.ResourceRequirements
+}
+
+// planWorkloads decides how every service of the space is deployed. In a multi-service space only the ports marked
+// global are reachable from outside the cluster, the first service with a global http port is the primary service.
+func planWorkloads(spaceUuid, hostName string, hardwareResource models.Resource, containerResources []yaml.ContainerResource) ([]spaceWorkload, error) {
+	primaryResources, shareResources, err := splitOrder(hardwareResource, spaceContainers(containerResources))
+	if err != nil {
+		return nil, err
+	}
+	if len(containerResources) == 1 {
+		return []spaceWorkload{{Name: spaceUuid, HostName: hostName, Hardware: hardwareResource, Primary: true,
+			Resources: primaryResources, DependResources: shareResources}}, nil
+	}
+
+	primary := 0
+	for i := len(containerResources) - 1; i >= 0; i-- {
+		for _, expose := range containerResources[i].Exposes {
+			if expose.Http && expose.Global {
+				primary = i
+			}
+		}
+	}
+
+	workloads := make([]spaceWorkload, len(containerResources))
+	for i := range containerResources {
+		cr := &containerResources[i]
+		if cr.Batch != nil {
+			return nil, fmt.Errorf("the batch service %s can not be deployed with other services", cr.Name)
+		}
+		for j := range cr.Exposes {
+			if !cr.Exposes[j].Global {
+				cr.Exposes[j].Http = false
+			}
+		}
+
+		if i == primary {
+			workloads[i] = spaceWorkload{Name: spaceUuid, HostName: hostName, Hardware: hardwareResource, Primary: true,
+				Resources: primaryResources, DependResources: shareResources}
+			continue
+		}
+		workloads[i] = spaceWorkload{
+			Name:            dependResourceName(spaceUuid, cr.Name),
+			HostName:        serviceHostName(hostName, cr.Name),
+			Resources:       shareResources,
+			DependResources: shareResources,
+		}
+	}
+	return workloads, nil
+}
+
+// serviceHostName returns the host name a service other than the primary one is routed at: <prefix>-<service>.<domain>
+func serviceHostName(hostName, serviceName string) string {
+	serviceName = strings.ReplaceAll(strings.ToLower(serviceName), "_", "-")
+	if i := strings.Index(hostName, "."); i > 0 {
+		return fmt.Sprintf("%s-%s%s", hostName[:i], serviceName, hostName[i:])
+	}
+	return fmt.Sprintf("%s-%s", hostName, serviceName)
+}
+
+// createWorkloadServices creates the service of every workload of a multi-service space ahead of the deployments,
+// and returns the host aliases resolving the service names to them, so that services reach each other by name
+func createWorkloadServices(k8sNameSpace string, workloads []spaceWorkload, containerResources []yaml.ContainerResource) ([]coreV1.HostAlias, error) {
+	if len(workloads) < 2 {
+		return nil, nil
+	}
+
+	k8sService := NewK8sService()
+	var hostAliases []coreV1.HostAlias
+	for i, cr := range containerResources {
+		if len(cr.Ports) == 0 {
+			continue
+		}
+		service, err := k8sService.CreateService(context.TODO(), k8sNameSpace, workloads[i].Name, cr.Ports, httpPorts(cr.Exposes))
+		if err != nil {
+			return nil, fmt.Errorf("failed create service of %s, error: %w", cr.Name, err)
+		}
+		hostAliases = append(hostAliases, coreV1.HostAlias{
+			IP:        service.Spec.ClusterIP,
+			Hostnames: []string{cr.Name},
+		})
+		logs.GetLogger().Infof("Created service %s of %s, clusterIP: %s", service.Name, cr.Name, service.Spec.ClusterIP)
+	}
+	return hostAliases, nil
+}
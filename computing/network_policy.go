@@ -0,0 +1,238 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	coreV1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultIngressNamespace = "ingress-nginx"
+	cloudMetadataCidr       = "169.254.169.254/32"
+	namespaceNameLabel      = "kubernetes.io/metadata.name"
+)
+
+// applyNetworkPolicies isolates the namespace of a wallet: only the ingress controller and pods of the same namespace
+// can reach its spaces, and on egress they reach the same namespace, the ingress controller, the cluster DNS and the
+// addresses outside the cluster
+func applyNetworkPolicies(k8sNameSpace string) error {
+	policyConf := conf.GetConfig().NetworkPolicy
+	if !policyConf.Enable {
+		return nil
+	}
+
+	k8sService := NewK8sService()
+	clusterApiIps, err := k8sService.GetClusterApiIps(context.TODO())
+	if err != nil {
+		return fmt.Errorf("failed get cluster api address, error: %w", err)
+	}
+
+	ingressNamespace := valueOrDefault(policyConf.IngressNamespace, defaultIngressNamespace)
+	return createNetworkPolicies(k8sService, k8sNameSpace,
+		ingressNetworkPolicy(k8sNameSpace, ingressNamespace),
+		egressNetworkPolicy(k8sNameSpace, ingressNamespace, egressDenyCidrs(policyConf, clusterApiIps), policyConf.AllowEgressCidrs))
+}
+
+// applyBuildNetworkPolicies isolates the namespace of the build jobs: no pod reaches the build pods, which reach the
+// same egress as spaces to pull the base images and push the images
+func applyBuildNetworkPolicies(k8sService *K8sService, namespace string) error {
+	policyConf := conf.GetConfig().NetworkPolicy
+	if !policyConf.Enable {
+		return nil
+	}
+
+	clusterApiIps, err := k8sService.GetClusterApiIps(context.TODO())
+	if err != nil {
+		return fmt.Errorf("failed get cluster api address, error: %w", err)
+	}
+	denyIngress := &networkingv1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_NETWORK_POLICY_INGRESS,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	ingressNamespace := valueOrDefault(policyConf.IngressNamespace, defaultIngressNamespace)
+	return createNetworkPolicies(k8sService, namespace, denyIngress,
+		egressNetworkPolicy(namespace, ingressNamespace, egressDenyCidrs(policyConf, clusterApiIps), policyConf.AllowEgressCidrs))
+}
+
+func createNetworkPolicies(k8sService *K8sService, namespace string, networkPolicies ...*networkingv1.NetworkPolicy) error {
+	for _, networkPolicy := range networkPolicies {
+		if _, err := k8sService.CreateNetworkPolicy(context.TODO(), namespace, networkPolicy); err != nil {
+			return fmt.Errorf("failed create networkPolicy %s, error: %w", networkPolicy.Name, err)
+		}
+	}
+	logs.GetLogger().Infof("Applied network policies, namespace: %s", namespace)
+	return nil
+}
+
+// egressDenyCidrs returns the addresses blocked on egress: the pods and services of the cluster, the cluster API, the
+// cloud metadata and the configured CIDRs. The services are reached at the pod IPs once kube-proxy has translated
+// their ClusterIPs, the pod CIDRs are what keeps a space from the other tenants.
+func egressDenyCidrs(policyConf conf.NetworkPolicy, clusterApiIps []string) []string {
+	denyCidrs := []string{cloudMetadataCidr}
+	denyCidrs = append(denyCidrs, policyConf.PodCidrs...)
+	denyCidrs = append(denyCidrs, policyConf.ServiceCidrs...)
+	denyCidrs = append(denyCidrs, policyConf.DenyEgressCidrs...)
+	for _, ip := range clusterApiIps {
+		denyCidrs = append(denyCidrs, hostCidr(ip))
+	}
+	return denyCidrs
+}
+
+func ingressNetworkPolicy(k8sNameSpace, ingressNamespace string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_NETWORK_POLICY_INGRESS,
+			Namespace: k8sNameSpace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						namespacePeer(ingressNamespace),
+						{PodSelector: &metaV1.LabelSelector{}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func egressNetworkPolicy(k8sNameSpace, ingressNamespace string, denyCidrs, allowCidrs []string) *networkingv1.NetworkPolicy {
+	egressRules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metaV1.LabelSelector{}}},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{namespacePeer(ingressNamespace)},
+			Ports: []networkingv1.NetworkPolicyPort{
+				networkPolicyPort(coreV1.ProtocolTCP, 80),
+				networkPolicyPort(coreV1.ProtocolTCP, 443),
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{namespacePeer(metaV1.NamespaceSystem)},
+			Ports: []networkingv1.NetworkPolicyPort{
+				networkPolicyPort(coreV1.ProtocolUDP, 53),
+				networkPolicyPort(coreV1.ProtocolTCP, 53),
+			},
+		},
+		{
+			To: publicIpBlocks(denyCidrs),
+		},
+	}
+	if len(allowCidrs) > 0 {
+		var allowPeers []networkingv1.NetworkPolicyPeer
+		for _, cidr := range allowCidrs {
+			allowPeers = append(allowPeers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		egressRules = append(egressRules, networkingv1.NetworkPolicyEgressRule{To: allowPeers})
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_NETWORK_POLICY_EGRESS,
+			Namespace: k8sNameSpace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egressRules,
+		},
+	}
+}
+
+func namespacePeer(namespace string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metaV1.LabelSelector{
+			MatchLabels: map[string]string{namespaceNameLabel: namespace},
+		},
+	}
+}
+
+// allowExternalTraffic lets traffic from outside the cluster reach the raw ports a space publishes, the pods of the
+// other tenants are still kept out
+func allowExternalTraffic(k8sNameSpace, spaceUuid string, servicePorts []coreV1.ServicePort) error {
+	policyConf := conf.GetConfig().NetworkPolicy
+	if !policyConf.Enable {
+		return nil
+	}
+
+	var ports []networkingv1.NetworkPolicyPort
+	for _, servicePort := range servicePorts {
+		ports = append(ports, networkPolicyPort(servicePort.Protocol, servicePort.TargetPort.IntVal))
+	}
+	networkPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_SERVICE_NAME_PREFIX + spaceUuid + constants.K8S_EXTERNAL_SERVICE_SUFFIX,
+			Namespace: k8sNameSpace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metaV1.LabelSelector{
+				MatchLabels: map[string]string{"lad_app": spaceUuid},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From:  publicIpBlocks(policyConf.PodCidrs),
+					Ports: ports,
+				},
+			},
+		},
+	}
+	_, err := NewK8sService().CreateNetworkPolicy(context.TODO(), k8sNameSpace, networkPolicy)
+	return err
+}
+
+func deleteExternalTrafficPolicy(k8sNameSpace, spaceUuid string) {
+	name := constants.K8S_SERVICE_NAME_PREFIX + spaceUuid + constants.K8S_EXTERNAL_SERVICE_SUFFIX
+	if err := NewK8sService().DeleteNetworkPolicy(context.TODO(), k8sNameSpace, name); err != nil && !errors.IsNotFound(err) {
+		logs.GetLogger().Errorf("Failed delete networkPolicy, name: %s, error: %+v", name, err)
+	}
+}
+
+// publicIpBlocks returns the IPv4 and IPv6 blocks of every address except the given CIDRs
+func publicIpBlocks(exceptCidrs []string) []networkingv1.NetworkPolicyPeer {
+	ipv4 := &networkingv1.IPBlock{CIDR: "0.0.0.0/0"}
+	ipv6 := &networkingv1.IPBlock{CIDR: "::/0"}
+	for _, cidr := range exceptCidrs {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logs.GetLogger().Warnf("Ignored invalid CIDR %s of network policy", cidr)
+			continue
+		}
+		if ip.To4() != nil {
+			ipv4.Except = append(ipv4.Except, cidr)
+		} else {
+			ipv6.Except = append(ipv6.Except, cidr)
+		}
+	}
+	return []networkingv1.NetworkPolicyPeer{{IPBlock: ipv4}, {IPBlock: ipv6}}
+}
+
+func hostCidr(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+func networkPolicyPort(protocol coreV1.Protocol, port int32) networkingv1.NetworkPolicyPort {
+	portValue := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &protocol,
+		Port:     &portValue,
+	}
+}
@@ -0,0 +1,98 @@
+package computing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+	networkingv1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEgressDenyCidrs(t *testing.T) {
+	policyConf := conf.NetworkPolicy{
+		PodCidrs:        []string{"10.244.0.0/16", "fd00:10:244::/56"},
+		ServiceCidrs:    []string{"10.96.0.0/12"},
+		DenyEgressCidrs: []string{"192.168.0.0/16"},
+	}
+	got := egressDenyCidrs(policyConf, []string{"10.96.0.1", "172.18.0.2", "fd00::1"})
+	want := []string{cloudMetadataCidr, "10.244.0.0/16", "fd00:10:244::/56", "10.96.0.0/12", "192.168.0.0/16",
+		"10.96.0.1/32", "172.18.0.2/32", "fd00::1/128"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEgressNetworkPolicy(t *testing.T) {
+	denyCidrs := []string{cloudMetadataCidr, "10.244.0.0/16", "10.96.0.0/12", "fd00:10:244::/56"}
+	policy := egressNetworkPolicy("ns-0xabc", "ingress-nginx", denyCidrs, []string{"10.96.0.10/32"})
+
+	if policy.Namespace != "ns-0xabc" || !reflect.DeepEqual(policy.Spec.PolicyTypes, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}) {
+		t.Fatalf("unexpected policy %s/%s of types %v", policy.Namespace, policy.Name, policy.Spec.PolicyTypes)
+	}
+	if !reflect.DeepEqual(policy.Spec.PodSelector, metaV1.LabelSelector{}) {
+		t.Fatalf("the policy must select every pod of the namespace, got %+v", policy.Spec.PodSelector)
+	}
+
+	rules := policy.Spec.Egress
+	if len(rules) != 5 {
+		t.Fatalf("got %d egress rules, want 5", len(rules))
+	}
+
+	tests := []struct {
+		name      string
+		rule      networkingv1.NetworkPolicyEgressRule
+		namespace string
+		ports     []int
+	}{
+		{name: "ingress controller", rule: rules[1], namespace: "ingress-nginx", ports: []int{80, 443}},
+		{name: "cluster dns", rule: rules[2], namespace: metaV1.NamespaceSystem, ports: []int{53, 53}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.rule.To) != 1 || tt.rule.To[0].NamespaceSelector == nil ||
+				tt.rule.To[0].NamespaceSelector.MatchLabels[namespaceNameLabel] != tt.namespace {
+				t.Fatalf("the rule must only allow the namespace %s, got %+v", tt.namespace, tt.rule.To)
+			}
+			var ports []int
+			for _, port := range tt.rule.Ports {
+				ports = append(ports, port.Port.IntValue())
+			}
+			if !reflect.DeepEqual(ports, tt.ports) {
+				t.Fatalf("got ports %v, want %v", ports, tt.ports)
+			}
+		})
+	}
+
+	if len(rules[0].To) != 1 || rules[0].To[0].PodSelector == nil || rules[0].To[0].NamespaceSelector != nil {
+		t.Fatalf("the first rule must only allow the pods of the same namespace, got %+v", rules[0].To)
+	}
+
+	public := rules[3].To
+	if len(public) != 2 || len(rules[3].Ports) != 0 {
+		t.Fatalf("got public rule %+v, want an IPv4 and an IPv6 block", rules[3])
+	}
+	if got := public[0].IPBlock; got.CIDR != "0.0.0.0/0" ||
+		!reflect.DeepEqual(got.Except, []string{cloudMetadataCidr, "10.244.0.0/16", "10.96.0.0/12"}) {
+		t.Fatalf("unexpected IPv4 block %+v", got)
+	}
+	if got := public[1].IPBlock; got.CIDR != "::/0" || !reflect.DeepEqual(got.Except, []string{"fd00:10:244::/56"}) {
+		t.Fatalf("unexpected IPv6 block %+v", got)
+	}
+
+	if got := rules[4].To; len(got) != 1 || got[0].IPBlock.CIDR != "10.96.0.10/32" {
+		t.Fatalf("unexpected allowed CIDRs %+v", got)
+	}
+}
+
+func TestIngressNetworkPolicy(t *testing.T) {
+	policy := ingressNetworkPolicy("ns-0xabc", "ingress-nginx")
+	if len(policy.Spec.Ingress) != 1 {
+		t.Fatalf("got %d ingress rules, want 1", len(policy.Spec.Ingress))
+	}
+	from := policy.Spec.Ingress[0].From
+	if len(from) != 2 || from[0].NamespaceSelector.MatchLabels[namespaceNameLabel] != "ingress-nginx" ||
+		from[1].PodSelector == nil || from[1].NamespaceSelector != nil || from[1].IPBlock != nil {
+		t.Fatalf("only the ingress controller and the same namespace may reach the spaces, got %+v", from)
+	}
+}
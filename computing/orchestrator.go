@@ -0,0 +1,180 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lagrangedao/go-computing-provider/common"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/models"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+const (
+	orchestratorKubernetes = "kubernetes"
+	orchestratorDocker     = "docker"
+	defaultLogTailLines    = 200
+)
+
+// Orchestrator runs the spaces of the provider. Deploying a space replaces the space when it is already running,
+// deleting it releases what it kept, e.g. its persistent volumes and custom domains.
+type Orchestrator interface {
+	DeployDockerfile(jobUuid, hostName, creatorWallet, spaceUuid, imageName, dockerfilePath string, hardwareResource models.Resource, duration int) error
+	DeployYaml(jobUuid, creatorWallet, spaceUuid, yamlPath, hostName string, hardwareResource models.Resource, duration int) error
+	Delete(namespace, spaceUuid string)
+	Status(namespace, spaceUuid string) ([]models.SpaceContainer, error)
+	Logs(namespace, spaceUuid string, tailLines int64) (string, error)
+	NodeResources(ctx context.Context) ([]*models.NodeResource, error)
+}
+
+var orchestrator Orchestrator
+var orchestratorOnce sync.Once
+
+// getOrchestrator returns the orchestrator configured by Orchestrator.Type, Kubernetes by default
+func getOrchestrator() Orchestrator {
+	orchestratorOnce.Do(func() {
+		if isDockerOrchestrator() {
+			orchestrator = newDockerOrchestrator()
+		} else {
+			orchestrator = &k8sOrchestrator{}
+		}
+	})
+	return orchestrator
+}
+
+func isDockerOrchestrator() bool {
+	return strings.EqualFold(conf.GetConfig().Orchestrator.Type, orchestratorDocker)
+}
+
+// k8sOrchestrator runs every space in the namespace of its wallet on the Kubernetes cluster
+type k8sOrchestrator struct{}
+
+func (o *k8sOrchestrator) DeployDockerfile(jobUuid, hostName, creatorWallet, spaceUuid, imageName, dockerfilePath string, hardwareResource models.Resource, duration int) error {
+	return dockerfileToK8s(jobUuid, hostName, creatorWallet, spaceUuid, imageName, dockerfilePath, hardwareResource, duration)
+}
+
+func (o *k8sOrchestrator) DeployYaml(jobUuid, creatorWallet, spaceUuid, yamlPath, hostName string, hardwareResource models.Resource, duration int) error {
+	return yamlToK8s(jobUuid, creatorWallet, spaceUuid, yamlPath, hostName, hardwareResource, duration)
+}
+
+func (o *k8sOrchestrator) Delete(namespace, spaceUuid string) {
+	deleteJob(namespace, spaceUuid)
+	releaseSpace(namespace, spaceUuid)
+}
+
+func (o *k8sOrchestrator) Status(namespace, spaceUuid string) ([]models.SpaceContainer, error) {
+	k8sService := NewK8sService()
+	pods, err := o.spacePods(k8sService, namespace, spaceUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []models.SpaceContainer
+	for _, pod := range pods {
+		reason, _ := podFailureReason(&pod)
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			state := "waiting"
+			if status.State.Running != nil {
+				state = "running"
+			} else if status.State.Terminated != nil {
+				state = "exited"
+			}
+			containers = append(containers, models.SpaceContainer{
+				Name:     status.Name,
+				Workload: pod.Name,
+				State:    state,
+				Ready:    status.Ready,
+				Restarts: status.RestartCount,
+				Message:  reason,
+			})
+		}
+	}
+	return containers, nil
+}
+
+func (o *k8sOrchestrator) Logs(namespace, spaceUuid string, tailLines int64) (string, error) {
+	k8sService := NewK8sService()
+	pods, err := o.spacePods(k8sService, namespace, spaceUuid)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, pod := range pods {
+		containers := append(pod.Spec.InitContainers, pod.Spec.Containers...)
+		for _, container := range containers {
+			containerLog, err := k8sService.GetContainerLog(context.TODO(), namespace, pod.Name, container.Name, tailLines)
+			if err != nil {
+				containerLog = err.Error() + "\n"
+			}
+			fmt.Fprintf(&out, "==> %s/%s <==\n%s", pod.Name, container.Name, containerLog)
+		}
+	}
+	return out.String(), nil
+}
+
+// spacePods returns the pods of every workload of the space
+func (o *k8sOrchestrator) spacePods(k8sService *K8sService, namespace, spaceUuid string) ([]coreV1.Pod, error) {
+	workloads, err := k8sService.ListSpaceWorkloads(context.TODO(), namespace, spaceUuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed list workloads of the space, error: %w", err)
+	}
+	if len(workloads) == 0 {
+		workloads = []string{spaceUuid}
+	}
+
+	var pods []coreV1.Pod
+	for _, workload := range workloads {
+		workloadPods, err := k8sService.ListWorkloadPods(context.TODO(), namespace, workload)
+		if err != nil {
+			return nil, fmt.Errorf("failed get pods, error: %w", err)
+		}
+		pods = append(pods, workloadPods...)
+	}
+	return pods, nil
+}
+
+func (o *k8sOrchestrator) NodeResources(ctx context.Context) ([]*models.NodeResource, error) {
+	return NewK8sService().StatisticalSources(ctx)
+}
+
+// GetJobStatus returns the state of every container of the space of a job
+func GetJobStatus(c *gin.Context) {
+	jobRecord, err := getJobRecord(c.Query("job_uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	containers, err := getOrchestrator().Status(jobRecord.Namespace, jobRecord.SpaceUuid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, common.CreateSuccessResponse(containers))
+}
+
+// GetJobLogs returns the last lines the containers of the space of a job wrote
+func GetJobLogs(c *gin.Context) {
+	tailLines, err := strconv.ParseInt(c.DefaultQuery("tail", strconv.Itoa(defaultLogTailLines)), 10, 64)
+	if err != nil || tailLines <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tail must be a positive number"})
+		return
+	}
+	jobRecord, err := getJobRecord(c.Query("job_uuid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	spaceLogs, err := getOrchestrator().Logs(jobRecord.Namespace, jobRecord.SpaceUuid, tailLines)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.String(http.StatusOK, spaceLogs)
+}
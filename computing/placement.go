@@ -0,0 +1,171 @@
+package computing
+
+import (
+	"strconv"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	gpuTier               = "GPU"
+	antiAffinityPreferred = "preferred"
+	antiAffinityRequired  = "required"
+)
+
+// tierPlacement returns the placement of the hardware tier, the one of GPU applies to every GPU model without its own
+func tierPlacement(tier string) (conf.TierPlacement, bool) {
+	placements := conf.GetConfig().Placement
+	if placement, ok := placements[tier]; ok {
+		return placement, true
+	}
+	if tier != cpuTier {
+		placement, ok := placements[gpuTier]
+		return placement, ok
+	}
+	return conf.TierPlacement{}, false
+}
+
+// applyPlacement adds the node selector, node affinity, tolerations and pod anti-affinity of the hardware tier to the pod
+func applyPlacement(podSpec *coreV1.PodSpec, spaceUuid string, hardwareResource models.Resource) {
+	placement, ok := tierPlacement(hardwareTier(hardwareResource))
+	if !ok {
+		return
+	}
+
+	if len(placement.NodeSelector) > 0 && podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = map[string]string{}
+	}
+	for key, value := range placement.NodeSelector {
+		podSpec.NodeSelector[key] = value
+	}
+	for _, toleration := range placement.Tolerations {
+		podSpec.Tolerations = append(podSpec.Tolerations, coreV1.Toleration{
+			Key:      toleration.Key,
+			Operator: coreV1.TolerationOperator(toleration.Operator),
+			Value:    toleration.Value,
+			Effect:   coreV1.TaintEffect(toleration.Effect),
+		})
+	}
+
+	var affinity coreV1.Affinity
+	if len(placement.NodeAffinity) > 0 {
+		var requirements []coreV1.NodeSelectorRequirement
+		for _, rule := range placement.NodeAffinity {
+			requirements = append(requirements, coreV1.NodeSelectorRequirement{
+				Key:      rule.Key,
+				Operator: coreV1.NodeSelectorOperator(rule.Operator),
+				Values:   rule.Values,
+			})
+		}
+		affinity.NodeAffinity = &coreV1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &coreV1.NodeSelector{
+				NodeSelectorTerms: []coreV1.NodeSelectorTerm{{MatchExpressions: requirements}},
+			},
+		}
+	}
+
+	spaceTerm := coreV1.PodAffinityTerm{
+		LabelSelector: &metaV1.LabelSelector{
+			MatchLabels: map[string]string{"lad_app": spaceUuid},
+		},
+		TopologyKey: coreV1.LabelHostname,
+	}
+	switch placement.AntiAffinity {
+	case antiAffinityPreferred:
+		affinity.PodAntiAffinity = &coreV1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []coreV1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: spaceTerm},
+			},
+		}
+	case antiAffinityRequired:
+		affinity.PodAntiAffinity = &coreV1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []coreV1.PodAffinityTerm{spaceTerm},
+		}
+	}
+	if affinity.NodeAffinity != nil || affinity.PodAntiAffinity != nil {
+		podSpec.Affinity = &affinity
+	}
+}
+
+// nodeUsableByTier reports whether the spaces of the hardware tier can be scheduled on the node:
+// it matches the node selector and node affinity of the tier, and every taint of the node is tolerated
+func nodeUsableByTier(node *coreV1.Node, tier string) bool {
+	placement, _ := tierPlacement(tier)
+	for key, value := range placement.NodeSelector {
+		if node.Labels[key] != value {
+			return false
+		}
+	}
+	for _, rule := range placement.NodeAffinity {
+		if !matchNodeAffinityRule(node.Labels, rule) {
+			return false
+		}
+	}
+
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == coreV1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range placement.Tolerations {
+			t := coreV1.Toleration{
+				Key:      toleration.Key,
+				Operator: coreV1.TolerationOperator(toleration.Operator),
+				Value:    toleration.Value,
+				Effect:   coreV1.TaintEffect(toleration.Effect),
+			}
+			if t.ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+func matchNodeAffinityRule(labels map[string]string, rule conf.NodeAffinityRule) bool {
+	value, exists := labels[rule.Key]
+	switch coreV1.NodeSelectorOperator(rule.Operator) {
+	case coreV1.NodeSelectorOpIn:
+		return exists && containsString(rule.Values, value)
+	case coreV1.NodeSelectorOpNotIn:
+		return !exists || !containsString(rule.Values, value)
+	case coreV1.NodeSelectorOpExists:
+		return exists
+	case coreV1.NodeSelectorOpDoesNotExist:
+		return !exists
+	case coreV1.NodeSelectorOpGt, coreV1.NodeSelectorOpLt:
+		if !exists || len(rule.Values) != 1 {
+			return false
+		}
+		nodeValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		ruleValue, err := strconv.ParseInt(rule.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if rule.Operator == string(coreV1.NodeSelectorOpGt) {
+			return nodeValue > ruleValue
+		}
+		return nodeValue < ruleValue
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
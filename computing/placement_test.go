@@ -0,0 +1,44 @@
+package computing
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeUsableByTier(t *testing.T) {
+	gpuTaint := coreV1.Taint{Key: "nvidia.com/gpu", Value: "present", Effect: coreV1.TaintEffectNoSchedule}
+	node := func(labels map[string]string, taints ...coreV1.Taint) *coreV1.Node {
+		return &coreV1.Node{
+			ObjectMeta: metaV1.ObjectMeta{Labels: labels},
+			Spec:       coreV1.NodeSpec{Taints: taints},
+		}
+	}
+
+	tests := []struct {
+		name string
+		node *coreV1.Node
+		tier string
+		want bool
+	}{
+		{name: "cpu on an untainted node", node: node(nil), tier: cpuTier, want: true},
+		{name: "cpu does not tolerate the gpu taint", node: node(nil, gpuTaint), tier: cpuTier},
+		{name: "cpu ignores prefer no schedule taints", tier: cpuTier, want: true,
+			node: node(nil, coreV1.Taint{Key: "spot", Effect: coreV1.TaintEffectPreferNoSchedule})},
+		{name: "gpu on a gpu node", node: node(map[string]string{"lad/pool": "gpu"}, gpuTaint), tier: "NVIDIA T4", want: true},
+		{name: "gpu requires the node selector", node: node(map[string]string{"lad/pool": "cpu"}, gpuTaint), tier: "NVIDIA T4"},
+		{name: "gpu does not tolerate other taints", node: node(map[string]string{"lad/pool": "gpu"},
+			coreV1.Taint{Key: "dedicated", Value: "db", Effect: coreV1.TaintEffectNoExecute}), tier: "NVIDIA T4"},
+		{name: "model in the affinity zone", node: node(map[string]string{"lad/zone": "b"}), tier: "NVIDIA A100", want: true},
+		{name: "model outside the affinity zone", node: node(map[string]string{"lad/zone": "c"}), tier: "NVIDIA A100"},
+		{name: "model placement replaces the gpu one", node: node(map[string]string{"lad/zone": "a"}, gpuTaint), tier: "NVIDIA A100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nodeUsableByTier(tt.node, tt.tier); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package computing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/docker"
+)
+
+const (
+	defaultProxyAddress = ":80"
+	proxyRouteTtl       = 10 * time.Second
+	proxyMaxRoutes      = 4096
+)
+
+// spaceProxy routes the requests to the host name of a space to the port the container serving it published on the
+// loopback interface, it is the ingress of the docker orchestrator. The routes are read from the labels of the
+// containers, so they survive restarts of the provider and of the containers.
+type spaceProxy struct {
+	dockerService *docker.DockerService
+	proxy         *httputil.ReverseProxy
+	lock          sync.Mutex
+	routes        map[string]proxyRoute
+}
+
+type proxyRoute struct {
+	target   string
+	expireAt time.Time
+}
+
+// serveSpaceProxy serves the host names of the spaces, over HTTPS when a certificate is configured
+func serveSpaceProxy() {
+	orchestratorConf := conf.GetConfig().Orchestrator
+	address := orchestratorConf.ProxyAddress
+	if address == "" {
+		address = defaultProxyAddress
+	}
+
+	p := &spaceProxy{
+		dockerService: docker.NewDockerService(),
+		routes:        make(map[string]proxyRoute),
+	}
+	p.proxy = &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.Header.Set("X-Forwarded-Host", r.Host)
+		},
+	}
+	server := &http.Server{Addr: address, Handler: p}
+
+	var err error
+	logs.GetLogger().Infof("Space proxy listening on %s", address)
+	if orchestratorConf.ProxyTlsCert != "" && orchestratorConf.ProxyTlsKey != "" {
+		err = server.ListenAndServeTLS(orchestratorConf.ProxyTlsCert, orchestratorConf.ProxyTlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	logs.GetLogger().Errorf("Space proxy stopped, error: %+v", err)
+}
+
+func (p *spaceProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	target, err := p.resolve(host)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed resolve the route of %s, error: %+v", host, err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	if target == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.URL.Scheme = "http"
+	r.URL.Host = target
+	p.proxy.ServeHTTP(w, r)
+}
+
+// resolve returns the address of the running container routed at the host name, empty when there is none
+func (p *spaceProxy) resolve(host string) (string, error) {
+	p.lock.Lock()
+	route, ok := p.routes[host]
+	p.lock.Unlock()
+	if ok && time.Now().Before(route.expireAt) {
+		return route.target, nil
+	}
+
+	containers, err := p.dockerService.ListContainers(context.TODO(), map[string]string{dockerLabelRoute + host: ""})
+	if err != nil {
+		return "", err
+	}
+	var target string
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		containerPort, _ := strconv.Atoi(c.Labels[dockerLabelRoute+host])
+		for _, port := range c.Ports {
+			if int(port.PrivatePort) == containerPort && port.Type == "tcp" && port.PublicPort != 0 {
+				target = net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port.PublicPort)))
+			}
+		}
+	}
+
+	p.lock.Lock()
+	if len(p.routes) >= proxyMaxRoutes {
+		p.routes = make(map[string]proxyRoute)
+	}
+	p.routes[host] = proxyRoute{target: target, expireAt: time.Now().Add(proxyRouteTtl)}
+	p.lock.Unlock()
+	return target, nil
+}
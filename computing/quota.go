@@ -0,0 +1,106 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultContainerCpu     = "500m"
+	defaultContainerMemory  = "512Mi"
+	defaultContainerStorage = "1Gi"
+)
+
+// applyWalletQuota limits the resources all spaces of a wallet can request together,
+// and gives the containers requesting no resources a default, which the quota requires
+func applyWalletQuota(k8sNameSpace string) error {
+	quotaConf := conf.GetConfig().WalletQuota
+	if !quotaConf.Enable {
+		return nil
+	}
+
+	hard := coreV1.ResourceList{}
+	for name, value := range map[coreV1.ResourceName]string{
+		coreV1.ResourceRequestsCPU:              quotaConf.Cpu,
+		coreV1.ResourceLimitsCPU:                quotaConf.Cpu,
+		coreV1.ResourceRequestsMemory:           quotaConf.Memory,
+		coreV1.ResourceLimitsMemory:             quotaConf.Memory,
+		coreV1.ResourceRequestsEphemeralStorage: quotaConf.EphemeralStorage,
+		coreV1.ResourceLimitsEphemeralStorage:   quotaConf.EphemeralStorage,
+	} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("invalid wallet quota %s: %s", name, value)
+		}
+		hard[name] = quantity
+	}
+	for gpuResource, count := range quotaConf.Gpus {
+		hard[coreV1.ResourceName("requests."+gpuResource)] = *resource.NewQuantity(int64(count), resource.DecimalSI)
+	}
+	if quotaConf.Pods > 0 {
+		hard[coreV1.ResourcePods] = *resource.NewQuantity(int64(quotaConf.Pods), resource.DecimalSI)
+	}
+
+	defaults := coreV1.ResourceList{}
+	for name, value := range map[coreV1.ResourceName]string{
+		coreV1.ResourceCPU:              valueOrDefault(quotaConf.DefaultCpu, defaultContainerCpu),
+		coreV1.ResourceMemory:           valueOrDefault(quotaConf.DefaultMemory, defaultContainerMemory),
+		coreV1.ResourceEphemeralStorage: valueOrDefault(quotaConf.DefaultEphemeralStorage, defaultContainerStorage),
+	} {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("invalid default container resource %s: %s", name, value)
+		}
+		defaults[name] = quantity
+	}
+
+	k8sService := NewK8sService()
+	resourceQuota := &coreV1.ResourceQuota{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_WALLET_QUOTA_NAME,
+			Namespace: k8sNameSpace,
+		},
+		Spec: coreV1.ResourceQuotaSpec{Hard: hard},
+	}
+	if _, err := k8sService.CreateResourceQuota(context.TODO(), k8sNameSpace, resourceQuota); err != nil {
+		return fmt.Errorf("failed create resourceQuota, error: %w", err)
+	}
+
+	limitRange := &coreV1.LimitRange{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      constants.K8S_WALLET_QUOTA_NAME,
+			Namespace: k8sNameSpace,
+		},
+		Spec: coreV1.LimitRangeSpec{
+			Limits: []coreV1.LimitRangeItem{
+				{
+					Type:           coreV1.LimitTypeContainer,
+					Default:        defaults,
+					DefaultRequest: defaults,
+				},
+			},
+		},
+	}
+	if _, err := k8sService.CreateLimitRange(context.TODO(), k8sNameSpace, limitRange); err != nil {
+		return fmt.Errorf("failed create limitRange, error: %w", err)
+	}
+	logs.GetLogger().Infof("Applied wallet quota, namespace: %s", k8sNameSpace)
+	return nil
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,377 @@
+package computing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8sYaml "sigs.k8s.io/yaml"
+)
+
+type renderJobReq struct {
+	JobSourceURI  string `json:"job_source_uri" form:"job_source_uri"`
+	DeployYaml    string `json:"deploy_yaml" form:"deploy_yaml"`
+	Hardware      string `json:"hardware" form:"hardware"`
+	CreatorWallet string `json:"creator_wallet" form:"creator_wallet"`
+	SpaceUuid     string `json:"space_uuid" form:"space_uuid"`
+	Duration      int    `json:"duration" form:"duration"`
+}
+
+// RenderJob returns the manifests the provider would deploy a space with, as YAML documents, without applying them.
+// The space is given by its source URI or by an uploaded deploy.yaml with the hardware description of the order.
+func RenderJob(c *gin.Context) {
+	var req renderJobReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if fileHeader, err := c.FormFile("deploy_yaml"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		deployYaml, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.DeployYaml = string(deployYaml)
+	}
+	if req.JobSourceURI == "" && req.DeployYaml == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job_source_uri or deploy_yaml is required"})
+		return
+	}
+
+	manifests, err := renderSpace(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", manifests)
+}
+
+// renderSpace renders the manifests of the space with the builders the deployment uses. Resources only known once
+// deployed are left out or shown as placeholders: the cluster IPs of the services and the node ports of the raw ports.
+func renderSpace(req renderJobReq) ([]byte, error) {
+	creatorWallet := strings.ToLower(req.CreatorWallet)
+	spaceUuid := strings.ToLower(req.SpaceUuid)
+	hardwareDescription := req.Hardware
+	var spaceName, yamlPath, imagePath string
+	var containsYaml bool
+
+	if req.DeployYaml != "" {
+		if creatorWallet == "" {
+			return nil, fmt.Errorf("creator_wallet is required to render an uploaded deploy.yaml")
+		}
+		if spaceUuid == "" {
+			spaceUuid = uuid.NewString()
+		}
+		dir, err := os.MkdirTemp("", "render-")
+		if err != nil {
+			return nil, fmt.Errorf("failed create render folder, error: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		yamlPath = filepath.Join(dir, "deploy.yaml")
+		if err = os.WriteFile(yamlPath, []byte(req.DeployYaml), 0644); err != nil {
+			return nil, fmt.Errorf("failed write deploy.yaml, error: %w", err)
+		}
+		containsYaml = true
+	} else {
+		spaceJson, err := fetchSpace(req.JobSourceURI)
+		if err != nil {
+			return nil, err
+		}
+		creatorWallet = strings.ToLower(spaceJson.Data.Owner.PublicAddress)
+		spaceName = strings.ToLower(spaceJson.Data.Space.Name)
+		spaceUuid = strings.ToLower(spaceJson.Data.Space.Uuid)
+		if hardwareDescription == "" {
+			hardwareDescription = spaceJson.Data.Space.ActiveOrder.Config.Description
+		}
+		// the build folder of the space may be in use by a deployment, the files are rendered from a folder of their own
+		dir, err := os.MkdirTemp("", "render-")
+		if err != nil {
+			return nil, fmt.Errorf("failed create render folder, error: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		containsYaml, yamlPath, imagePath, err = downloadSpaceFiles(dir, spaceUuid, spaceJson.Data.Files)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(strings.Split(hardwareDescription, "·")) < 3 {
+		return nil, fmt.Errorf("invalid hardware description %q, e.g. CPU only · 2 vCPU · 16 GiB", hardwareDescription)
+	}
+	hardwareResource := getHardwareDetail(hardwareDescription)
+
+	renderer := newManifestRenderer(creatorWallet, spaceUuid, newHostName(), req.Duration)
+	var err error
+	if containsYaml {
+		if err = admitYaml(yamlPath); err == nil {
+			err = renderer.renderYaml(yamlPath, hardwareResource)
+		}
+	} else {
+		var imageName string
+		if err = admitDockerfile(filepath.Join(imagePath, "Dockerfile")); err != nil {
+			return nil, err
+		}
+		if imageName, err = spaceImageName(spaceUuid, spaceName, imagePath); err == nil {
+			err = renderer.renderDockerfile(imageName, filepath.Join(imagePath, "Dockerfile"), hardwareResource)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return renderer.yaml()
+}
+
+type manifestRenderer struct {
+	jobUuid       string
+	creatorWallet string
+	k8sNameSpace  string
+	spaceUuid     string
+	hostName      string
+	duration      int
+	objects       []runtime.Object
+	names         map[string]bool
+}
+
+func newManifestRenderer(creatorWallet, spaceUuid, hostName string, duration int) *manifestRenderer {
+	return &manifestRenderer{
+		jobUuid:       uuid.NewString(),
+		creatorWallet: creatorWallet,
+		k8sNameSpace:  constants.K8S_NAMESPACE_NAME_PREFIX + creatorWallet,
+		spaceUuid:     spaceUuid,
+		hostName:      hostName,
+		duration:      duration,
+		names:         make(map[string]bool),
+	}
+}
+
+// add records the manifest of a resource, a resource created twice is kept as first created like the deployment does
+func (r *manifestRenderer) add(object runtime.Object) error {
+	kinds, _, err := scheme.Scheme.ObjectKinds(object)
+	if err != nil {
+		return fmt.Errorf("failed render manifest, error: %w", err)
+	}
+	object.GetObjectKind().SetGroupVersionKind(kinds[0])
+
+	objectMeta, err := meta.Accessor(object)
+	if err != nil {
+		return fmt.Errorf("failed render manifest, error: %w", err)
+	}
+	key := kinds[0].Kind + "/" + objectMeta.GetName()
+	if r.names[key] {
+		return nil
+	}
+	r.names[key] = true
+	r.objects = append(r.objects, object)
+	return nil
+}
+
+func (r *manifestRenderer) addAll(objects ...runtime.Object) error {
+	for _, object := range objects {
+		if err := r.add(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *manifestRenderer) yaml() ([]byte, error) {
+	var buf bytes.Buffer
+	for i, object := range r.objects {
+		data, err := k8sYaml.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed render manifest, error: %w", err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *manifestRenderer) renderYaml(yamlPath string, hardwareResource models.Resource) error {
+	containerResources, err := yaml.HandlerYaml(yamlPath)
+	if err != nil {
+		return err
+	}
+	if err = r.add(buildNamespace(r.creatorWallet)); err != nil {
+		return err
+	}
+
+	secret := newSpaceSecret(r.spaceUuid)
+	for i := range containerResources {
+		secret.addServiceEnv(&containerResources[i])
+	}
+	if len(secret.data) > 0 {
+		redacted := make(map[string]string, len(secret.data))
+		for key := range secret.data {
+			redacted[key] = redactedValue
+		}
+		if err = r.add(secret.manifest(r.k8sNameSpace, redacted)); err != nil {
+			return err
+		}
+	}
+
+	workloads, err := planWorkloads(r.spaceUuid, r.hostName, hardwareResource, containerResources)
+	if err != nil {
+		return err
+	}
+	var workloadAliases []coreV1.HostAlias
+	if len(workloads) > 1 {
+		for i, cr := range containerResources {
+			if len(cr.Ports) == 0 {
+				continue
+			}
+			service := buildService(r.k8sNameSpace, workloads[i].Name, cr.Ports, httpPorts(cr.Exposes))
+			if err = r.add(service); err != nil {
+				return err
+			}
+			workloadAliases = append(workloadAliases, clusterIpAlias(service, cr.Name))
+		}
+	}
+
+	for i, cr := range containerResources {
+		workload := workloads[i]
+		var volumes []coreV1.Volume
+		var volumeMounts []coreV1.VolumeMount
+		if cr.VolumeMounts.Path != "" {
+			configMap, err := buildConfigMap(r.k8sNameSpace, workload.Name, filepath.Dir(yamlPath), cr.VolumeMounts.Name)
+			if err != nil {
+				return err
+			}
+			if err = r.add(configMap); err != nil {
+				return err
+			}
+			volumes, volumeMounts = configMapVolume(r.spaceUuid, configMap, cr.VolumeMounts)
+		}
+
+		replicas, _, err := serviceReplicas(cr, workload.Hardware)
+		if err != nil {
+			return err
+		}
+		deployStrategy := appV1.DeploymentStrategy{}
+		volume, persistentMounts, _, err := persistentVolumeOf(workload.Name, cr.PersistentStorage)
+		if err != nil {
+			return err
+		}
+		if volume != nil {
+			volumes = append(volumes, *volume)
+			volumeMounts = append(volumeMounts, persistentMounts...)
+			deployStrategy.Type = appV1.RecreateDeploymentStrategyType
+		}
+
+		var hostAliases []coreV1.HostAlias
+		for _, depend := range cr.Depends {
+			if role, _ := dependRole(depend); role != dependRoleService {
+				continue
+			}
+			if len(depend.Ports) == 0 {
+				return fmt.Errorf("the depends-on service %s with the service role must expose a port", depend.Name)
+			}
+			service := buildDependService(r.k8sNameSpace, workload.Name, dependResourceName(workload.Name, depend.Name), depend.Ports)
+			if err = r.addAll(dependDeployment(r.creatorWallet, r.k8sNameSpace, workload.Name, depend, workload.DependResources), service); err != nil {
+				return err
+			}
+			hostAliases = append(hostAliases, clusterIpAlias(service, depend.Name))
+		}
+		hostAliases = append(hostAliases, workloadAliases...)
+
+		template, err := servicePodTemplate(r.jobUuid, r.creatorWallet, r.k8sNameSpace, r.spaceUuid, r.hostName, workload, cr, volumes, volumeMounts, hostAliases)
+		if err != nil {
+			return err
+		}
+		if cr.Batch != nil {
+			if err = r.add(batchJob(r.creatorWallet, r.k8sNameSpace, r.spaceUuid, workload, cr, template, r.duration)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		deployment := spaceDeployment(r.k8sNameSpace, r.spaceUuid, workload.Name, replicas, deployStrategy, template)
+		hardenPodSpec(&deployment.Spec.Template.Spec, r.creatorWallet, workload.Name, workload.Hardware)
+		if err = r.add(deployment); err != nil {
+			return err
+		}
+		if err = r.renderExposes(workload.Name, workload.HostName, cr.Exposes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *manifestRenderer) renderDockerfile(imageName, dockerfilePath string, hardwareResource models.Resource) error {
+	cr, err := dockerfileResource(imageName, dockerfilePath)
+	if err != nil {
+		return err
+	}
+	if err = r.add(buildNamespace(r.creatorWallet)); err != nil {
+		return err
+	}
+
+	var volumes []coreV1.Volume
+	var volumeMounts []coreV1.VolumeMount
+	deployStrategy := appV1.DeploymentStrategy{}
+	volume, persistentMounts, _, err := persistentVolumeOf(r.spaceUuid, cr.PersistentStorage)
+	if err != nil {
+		return err
+	}
+	if volume != nil {
+		volumes = append(volumes, *volume)
+		volumeMounts = append(volumeMounts, persistentMounts...)
+		deployStrategy.Type = appV1.RecreateDeploymentStrategyType
+	}
+
+	template, err := dockerfilePodTemplate(r.jobUuid, r.hostName, r.creatorWallet, r.k8sNameSpace, r.spaceUuid, cr, hardwareResource, volumes, volumeMounts)
+	if err != nil {
+		return err
+	}
+	deployment := spaceDeployment(r.k8sNameSpace, r.spaceUuid, r.spaceUuid, 1, deployStrategy, template)
+	hardenPodSpec(&deployment.Spec.Template.Spec, r.creatorWallet, r.spaceUuid, hardwareResource)
+	if err = r.add(deployment); err != nil {
+		return err
+	}
+	return r.renderExposes(r.spaceUuid, r.hostName, cr.Exposes)
+}
+
+// renderExposes renders the service and the ingress of a workload, as deployK8sResource creates them
+func (r *manifestRenderer) renderExposes(workloadName, hostName string, exposes []yaml.PortExpose) error {
+	containerPorts := exposedContainerPorts(exposes)
+	if len(containerPorts) == 0 {
+		return nil
+	}
+	if err := r.add(buildService(r.k8sNameSpace, workloadName, containerPorts, httpPorts(exposes))); err != nil {
+		return err
+	}
+	routes, _ := ingressRoutes(hostName, exposes)
+	if len(routes) == 0 {
+		return nil
+	}
+	return r.add(buildIngress(r.k8sNameSpace, workloadName, routes, ingressTLSOf(workloadName)))
+}
+
+// clusterIpAlias resolves the name to the service, its cluster IP is only known once the service is created
+func clusterIpAlias(service *coreV1.Service, hostName string) coreV1.HostAlias {
+	return coreV1.HostAlias{
+		IP:        fmt.Sprintf("<cluster-ip of %s>", service.Name),
+		Hostnames: []string{hostName},
+	}
+}
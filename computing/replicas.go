@@ -0,0 +1,115 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	autoscalingV2 "k8s.io/api/autoscaling/v2"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultMaxReplicas = 4
+	defaultTargetCpu   = 80
+)
+
+// maxReplicas returns how many replicas a space of the hardware tier can run
+func maxReplicas(hardwareResource models.Resource) int {
+	deployConf := conf.GetConfig().Deploy
+	tier := hardwareTier(hardwareResource)
+	if max := deployConf.TierMaxReplicas[tier]; max > 0 {
+		return max
+	}
+	if max := deployConf.TierMaxReplicas[gpuTier]; tier != cpuTier && max > 0 {
+		return max
+	}
+	if deployConf.MaxReplicas > 0 {
+		return deployConf.MaxReplicas
+	}
+	return defaultMaxReplicas
+}
+
+// serviceReplicas returns the replicas the deployment of a service starts with, and its autoscale bounded by the hardware tier
+func serviceReplicas(cr yaml.ContainerResource, hardwareResource models.Resource) (int32, *yaml.Autoscale, error) {
+	max := maxReplicas(hardwareResource)
+	replicas := cr.Count
+	if replicas <= 0 {
+		replicas = 1
+	}
+	if replicas > max {
+		return 0, nil, fmt.Errorf("the service %s requests %d replicas, the hardware tier allows %d", cr.Name, replicas, max)
+	}
+	if cr.Autoscale == nil {
+		return int32(replicas), nil, nil
+	}
+
+	autoscale := *cr.Autoscale
+	if autoscale.MinReplicas <= 0 {
+		autoscale.MinReplicas = replicas
+	}
+	if autoscale.MaxReplicas > max {
+		logs.GetLogger().Warnf("The service %s autoscales to %d replicas at most, the hardware tier allows %d", cr.Name, autoscale.MaxReplicas, max)
+		autoscale.MaxReplicas = max
+	}
+	if autoscale.MinReplicas > autoscale.MaxReplicas {
+		autoscale.MinReplicas = autoscale.MaxReplicas
+	}
+	if autoscale.TargetCpu <= 0 {
+		autoscale.TargetCpu = defaultTargetCpu
+	}
+	return int32(autoscale.MinReplicas), &autoscale, nil
+}
+
+// deployAutoscaler creates the HorizontalPodAutoscaler of the space deployment, or deletes it when the space does not autoscale
+func deployAutoscaler(k8sNameSpace, spaceUuid string, autoscale *yaml.Autoscale) error {
+	k8sService := NewK8sService()
+	hpaName := constants.K8S_HPA_NAME_PREFIX + spaceUuid
+	if autoscale == nil {
+		if err := k8sService.DeleteHorizontalPodAutoscaler(context.TODO(), k8sNameSpace, hpaName); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed delete autoscaler, error: %w", err)
+		}
+		return nil
+	}
+
+	minReplicas := int32(autoscale.MinReplicas)
+	targetCpu := int32(autoscale.TargetCpu)
+	hpa := &autoscalingV2.HorizontalPodAutoscaler{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      hpaName,
+			Namespace: k8sNameSpace,
+		},
+		Spec: autoscalingV2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingV2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       constants.K8S_DEPLOY_NAME_PREFIX + spaceUuid,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: int32(autoscale.MaxReplicas),
+			Metrics: []autoscalingV2.MetricSpec{
+				{
+					Type: autoscalingV2.ResourceMetricSourceType,
+					Resource: &autoscalingV2.ResourceMetricSource{
+						Name: coreV1.ResourceCPU,
+						Target: autoscalingV2.MetricTarget{
+							Type:               autoscalingV2.UtilizationMetricType,
+							AverageUtilization: &targetCpu,
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := k8sService.CreateHorizontalPodAutoscaler(context.TODO(), k8sNameSpace, hpa); err != nil {
+		return fmt.Errorf("failed create autoscaler, error: %w", err)
+	}
+	logs.GetLogger().Infof("Created autoscaler %s, replicas: %d-%d", hpaName, autoscale.MinReplicas, autoscale.MaxReplicas)
+	return nil
+}
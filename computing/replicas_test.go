@@ -0,0 +1,49 @@
+package computing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lagrangedao/go-computing-provider/models"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+)
+
+func TestServiceReplicas(t *testing.T) {
+	cpu := models.Resource{Cpu: models.Specification{Quantity: 2}}
+	t4 := models.Resource{Gpu: models.Specification{Quantity: 1, Unit: "NVIDIA T4"}}
+	a100 := models.Resource{Gpu: models.Specification{Quantity: 1, Unit: "NVIDIA A100"}}
+
+	tests := []struct {
+		name          string
+		cr            yaml.ContainerResource
+		hardware      models.Resource
+		wantReplicas  int32
+		wantAutoscale *yaml.Autoscale
+		wantErr       bool
+	}{
+		{name: "one replica by default", hardware: cpu, wantReplicas: 1},
+		{name: "the count of the service", cr: yaml.ContainerResource{Count: 3}, hardware: cpu, wantReplicas: 3},
+		{name: "more replicas than the tier allows", cr: yaml.ContainerResource{Count: 5}, hardware: cpu, wantErr: true},
+		{name: "the gpu tier bounds every model", cr: yaml.ContainerResource{Count: 3}, hardware: t4, wantErr: true},
+		{name: "the tier of the model", cr: yaml.ContainerResource{Count: 2}, hardware: a100, wantErr: true},
+		{name: "autoscale starts from the count", hardware: cpu,
+			cr:            yaml.ContainerResource{Count: 2, Autoscale: &yaml.Autoscale{MaxReplicas: 3}},
+			wantReplicas:  2,
+			wantAutoscale: &yaml.Autoscale{MinReplicas: 2, MaxReplicas: 3, TargetCpu: defaultTargetCpu}},
+		{name: "autoscale is bounded by the tier", hardware: t4,
+			cr:            yaml.ContainerResource{Autoscale: &yaml.Autoscale{MinReplicas: 3, MaxReplicas: 8, TargetCpu: 60}},
+			wantReplicas:  2,
+			wantAutoscale: &yaml.Autoscale{MinReplicas: 2, MaxReplicas: 2, TargetCpu: 60}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicas, autoscale, err := serviceReplicas(tt.cr, tt.hardware)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if replicas != tt.wantReplicas || !reflect.DeepEqual(autoscale, tt.wantAutoscale) {
+				t.Fatalf("got %d, %+v, want %d, %+v", replicas, autoscale, tt.wantReplicas, tt.wantAutoscale)
+			}
+		})
+	}
+}
@@ -99,19 +99,26 @@ func storageInPod(pod *corev1.Pod) (storageUsed int64) {
 		}
 		storageUsed += val.Value()
 	}
+	storageUsed += podOverhead(pod, corev1.ResourceEphemeralStorage)
 	return storageUsed
 }
 
+// cpuInPod returns the cores the pod requests, counted in millicores and rounded up once so that a fractional
+// overhead is not counted as a whole core
 func cpuInPod(pod *corev1.Pod) (cpuCount int64) {
+	var milliCpu int64
 	containers := pod.Spec.Containers
 	for _, container := range containers {
 		val, ok := container.Resources.Requests[corev1.ResourceCPU]
 		if !ok {
 			continue
 		}
-		cpuCount += val.Value()
+		milliCpu += val.MilliValue()
 	}
-	return cpuCount
+	if val, ok := pod.Spec.Overhead[corev1.ResourceCPU]; ok {
+		milliCpu += val.MilliValue()
+	}
+	return (milliCpu + 999) / 1000
 }
 
 func memInPod(pod *corev1.Pod) (memCount int64) {
@@ -123,23 +130,32 @@ func memInPod(pod *corev1.Pod) (memCount int64) {
 		}
 		memCount += val.Value()
 	}
+	memCount += podOverhead(pod, corev1.ResourceMemory)
 	return memCount
 }
 
+// podOverhead returns the bytes of memory or storage the runtime of the pod consumes besides its containers, e.g. a sandbox VM
+func podOverhead(pod *corev1.Pod, name corev1.ResourceName) int64 {
+	if val, ok := pod.Spec.Overhead[name]; ok {
+		return val.Value()
+	}
+	return 0
+}
+
 func gpuInPod(pod *corev1.Pod) (gpuName string, gpuCount int64) {
 	containers := pod.Spec.Containers
 	for _, container := range containers {
-		val, ok := container.Resources.Requests["nvidia.com/gpu"]
-		if !ok {
-			continue
+		for name, val := range container.Resources.Requests {
+			if isGpuResource(name) {
+				gpuCount += val.Value()
+			}
 		}
-		gpuCount += val.Value()
 	}
 
 	if pod.Spec.NodeSelector != nil {
-		for k := range pod.Spec.NodeSelector {
+		for k, v := range pod.Spec.NodeSelector {
 			if k != "" {
-				gpuName = k
+				gpuName = gpuNameOfSelector(k, v)
 			}
 		}
 	}
@@ -171,13 +187,18 @@ func checkClusterProviderStatus() (string, error) {
 		return "", err
 	}
 
+	nodesByName := make(map[string]*corev1.Node)
+	for i := range nodes.Items {
+		nodesByName[nodes.Items[i].Name] = &nodes.Items[i]
+	}
+
 	collectGpu := make(map[string]int64)
 	nodeGpuInfoMap, err := service.GetPodLog(context.TODO())
 	if err != nil {
 		logs.GetLogger().Error(err)
 		return "", err
 	}
-	for _, gpu := range nodeGpuInfoMap {
+	for nodeName, gpu := range nodeGpuInfoMap {
 		var gpuInfo struct {
 			Gpu models.Gpu `json:"gpu"`
 		}
@@ -186,6 +207,9 @@ func checkClusterProviderStatus() (string, error) {
 			continue
 		}
 		for _, gpuDetail := range gpuInfo.Gpu.Details {
+			if node, ok := nodesByName[nodeName]; ok && !nodeUsableByTier(node, gpuDetail.ProductName) {
+				continue
+			}
 			collectGpu[gpuDetail.ProductName] = collectGpu[gpuDetail.ProductName] + 1
 		}
 	}
@@ -197,6 +221,9 @@ func checkClusterProviderStatus() (string, error) {
 		for k, v := range gpuMap {
 			nodeGpu[k] = nodeGpu[k] + v
 		}
+		if !nodeUsableByTier(&node, cpuTier) {
+			continue
+		}
 		for k, v := range remainderResource {
 			nodeResource[k] = nodeGpu[k] + v
 		}
@@ -209,7 +236,7 @@ func checkClusterProviderStatus() (string, error) {
 		remainGpu[gpuName] = num - nodeGpu[gpuName]
 
 		for _, gpu := range policy.Gpu {
-			upperName := strings.ReplaceAll(gpu.Name, "Nvidia", "NVIDIA")
+			upperName := normalizeGpuModel(gpu.Name)
 			if gpuName == upperName {
 				policyMap[gpuName] = gpu.Quota
 				break
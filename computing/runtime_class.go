@@ -0,0 +1,40 @@
+package computing
+
+import (
+	"strings"
+
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/models"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+const cpuTier = "CPU"
+
+// applyRuntimeClass sets the RuntimeClass the pod of a space runs with: the one of trusted wallets,
+// else the one of the hardware tier, else the default one. The cluster default runtime is used when it is empty.
+func applyRuntimeClass(podSpec *coreV1.PodSpec, creatorWallet string, hardwareResource models.Resource) {
+	runtimeConf := conf.GetConfig().Runtime
+
+	className := runtimeConf.ClassName
+	if tierClassName, ok := runtimeConf.TierClasses[hardwareTier(hardwareResource)]; ok {
+		className = tierClassName
+	}
+	for _, wallet := range runtimeConf.TrustedWallets {
+		if strings.EqualFold(wallet, creatorWallet) {
+			className = runtimeConf.TrustedClassName
+			break
+		}
+	}
+
+	if className != "" {
+		podSpec.RuntimeClassName = &className
+	}
+}
+
+// hardwareTier returns the GPU model of the hardware, or CPU for hardware without GPU
+func hardwareTier(hardwareResource models.Resource) string {
+	if hardwareResource.Gpu.Quantity == 0 || hardwareResource.Gpu.Unit == "" {
+		return cpuTier
+	}
+	return hardwareResource.Gpu.Unit
+}
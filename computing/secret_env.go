@@ -0,0 +1,105 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const redactedValue = "******"
+
+// spaceSecret holds the secret env vars of the services of a space, they are stored in the secret of the space
+// and the containers reference them instead of carrying their values
+type spaceSecret struct {
+	name string
+	data map[string]string
+}
+
+func newSpaceSecret(spaceUuid string) *spaceSecret {
+	return &spaceSecret{
+		name: constants.K8S_SECRET_NAME_PREFIX + spaceUuid,
+		data: make(map[string]string),
+	}
+}
+
+// addServiceEnv moves the secret env vars of the service and of its depends-on services into the secret
+func (s *spaceSecret) addServiceEnv(cr *yaml.ContainerResource) {
+	for _, envVar := range cr.SecretEnv {
+		cr.Env = append(cr.Env, s.envVar(cr.Name, envVar))
+	}
+	for i := range cr.Depends {
+		depend := &cr.Depends[i]
+		for _, envVar := range depend.SecretEnv {
+			depend.Env = append(depend.Env, s.envVar(depend.Name, envVar))
+		}
+	}
+}
+
+// envVar stores the value of the env var in the secret, and returns the env var referencing it
+func (s *spaceSecret) envVar(serviceName string, envVar coreV1.EnvVar) coreV1.EnvVar {
+	key := serviceName + "." + envVar.Name
+	s.data[key] = envVar.Value
+	return coreV1.EnvVar{
+		Name: envVar.Name,
+		ValueFrom: &coreV1.EnvVarSource{
+			SecretKeyRef: &coreV1.SecretKeySelector{
+				LocalObjectReference: coreV1.LocalObjectReference{Name: s.name},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+// apply creates the secret of the space, or replaces its data when it already exists
+func (s *spaceSecret) apply(k8sNameSpace string) error {
+	if len(s.data) == 0 {
+		return nil
+	}
+	if _, err := NewK8sService().CreateSecret(context.TODO(), k8sNameSpace, s.manifest(k8sNameSpace, s.data)); err != nil {
+		return fmt.Errorf("failed create secret of the space, error: %w", s.redact(err))
+	}
+	return nil
+}
+
+func (s *spaceSecret) manifest(k8sNameSpace string, data map[string]string) *coreV1.Secret {
+	return &coreV1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      s.name,
+			Namespace: k8sNameSpace,
+		},
+		Type:       coreV1.SecretTypeOpaque,
+		StringData: data,
+	}
+}
+
+// redact replaces the secret values quoted in the error, e.g. by a rejected spec, before it is logged or reported
+func (s *spaceSecret) redact(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	for _, value := range s.data {
+		if value != "" {
+			message = strings.ReplaceAll(message, value, redactedValue)
+		}
+	}
+	if message == err.Error() {
+		return err
+	}
+	return errors.New(message)
+}
+
+func deleteSpaceSecret(k8sNameSpace, spaceUuid string) {
+	secretName := constants.K8S_SECRET_NAME_PREFIX + spaceUuid
+	if err := NewK8sService().DeleteSecret(context.TODO(), k8sNameSpace, secretName); err != nil && !k8sErrors.IsNotFound(err) {
+		logs.GetLogger().Errorf("Failed delete secret, secretName: %s, error: %+v", secretName, err)
+	}
+}
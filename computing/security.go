@@ -0,0 +1,156 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/docker"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultRunAsUser        = 1000
+	defaultPodSecurityLevel = "restricted"
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	podSecurityWarnLabel    = "pod-security.kubernetes.io/warn"
+	scratchVolumePrefix     = "scratch-"
+)
+
+var defaultWritablePaths = []string{"/tmp"}
+
+// applySecurityProfile hardens the pod of a space: it runs as a non-root user with the RuntimeDefault seccomp profile,
+// no capabilities, no privilege escalation, no service account token, and optionally a read-only root filesystem
+func applySecurityProfile(podSpec *coreV1.PodSpec) {
+	securityConf := conf.GetConfig().Security
+	if !securityConf.Enable {
+		return
+	}
+
+	runAsUser := securityConf.RunAsUser
+	if runAsUser <= 0 {
+		runAsUser = defaultRunAsUser
+	}
+	runAsNonRoot := true
+	automountToken := false
+	podSpec.AutomountServiceAccountToken = &automountToken
+	podSpec.SecurityContext = &coreV1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+		RunAsUser:    &runAsUser,
+		RunAsGroup:   &runAsUser,
+		FSGroup:      &runAsUser,
+		SeccompProfile: &coreV1.SeccompProfile{
+			Type: coreV1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+
+	var scratchMounts []coreV1.VolumeMount
+	if securityConf.ReadOnlyRootFilesystem {
+		writablePaths := securityConf.WritablePaths
+		if len(writablePaths) == 0 {
+			writablePaths = defaultWritablePaths
+		}
+		for i, path := range writablePaths {
+			name := fmt.Sprintf("%s%d", scratchVolumePrefix, i)
+			podSpec.Volumes = append(podSpec.Volumes, coreV1.Volume{
+				Name:         name,
+				VolumeSource: coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{}},
+			})
+			scratchMounts = append(scratchMounts, coreV1.VolumeMount{Name: name, MountPath: path})
+		}
+	}
+
+	for _, containers := range [][]coreV1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			privileged := false
+			allowPrivilegeEscalation := false
+			readOnlyRootFilesystem := securityConf.ReadOnlyRootFilesystem
+			containers[i].SecurityContext = &coreV1.SecurityContext{
+				Privileged:               &privileged,
+				AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+				ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+				Capabilities: &coreV1.Capabilities{
+					Drop: []coreV1.Capability{"ALL"},
+				},
+			}
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, scratchMounts...)
+		}
+	}
+}
+
+// applyDockerSecurityProfile hardens the container of a space run by the docker orchestrator like applySecurityProfile
+// does a pod: it runs as a non-root user with the default seccomp profile of docker, no capabilities, no privilege
+// escalation, and optionally a read-only root filesystem with tmpfs scratch paths. Unlike the ephemeral storage of a
+// pod, the writable layer of the container is not limited, docker only supports it on overlay2 over xfs with pquota.
+func applyDockerSecurityProfile(spec *docker.ContainerSpec) {
+	securityConf := conf.GetConfig().Security
+	if !securityConf.Enable {
+		return
+	}
+
+	runAsUser := securityConf.RunAsUser
+	if runAsUser <= 0 {
+		runAsUser = defaultRunAsUser
+	}
+	spec.User = fmt.Sprintf("%d:%d", runAsUser, runAsUser)
+	spec.CapDrop = []string{"ALL"}
+	spec.SecurityOpt = []string{"no-new-privileges"}
+	if securityConf.ReadOnlyRootFilesystem {
+		writablePaths := securityConf.WritablePaths
+		if len(writablePaths) == 0 {
+			writablePaths = defaultWritablePaths
+		}
+		spec.ReadonlyRootfs = true
+		spec.Tmpfs = make(map[string]string)
+		for _, path := range writablePaths {
+			spec.Tmpfs[path] = fmt.Sprintf("uid=%d,gid=%d", runAsUser, runAsUser)
+		}
+	}
+}
+
+// podSecurityLabels returns the Pod Security Admission labels of the wallet namespaces
+func podSecurityLabels() map[string]string {
+	securityConf := conf.GetConfig().Security
+	if !securityConf.Enable {
+		return nil
+	}
+	level := securityConf.PodSecurityLevel
+	if level == "" {
+		level = defaultPodSecurityLevel
+	}
+	return map[string]string{
+		podSecurityEnforceLabel: level,
+		podSecurityWarnLabel:    level,
+	}
+}
+
+// applyPodSecurityLabels labels an existing wallet namespace for Pod Security Admission
+func applyPodSecurityLabels(k8sNameSpace string) error {
+	labels := podSecurityLabels()
+	if len(labels) == 0 {
+		return nil
+	}
+
+	k8sService := NewK8sService()
+	namespace, err := k8sService.GetNameSpace(context.TODO(), k8sNameSpace, metaV1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	changed := false
+	for key, value := range labels {
+		if namespace.Labels[key] != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if _, err = k8sService.UpdateNameSpaceLabels(context.TODO(), k8sNameSpace, labels); err != nil {
+		return fmt.Errorf("failed label namespace for pod security, error: %w", err)
+	}
+	logs.GetLogger().Infof("Labeled namespace %s for pod security", k8sNameSpace)
+	return nil
+}
@@ -0,0 +1,36 @@
+package computing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/lagrangedao/go-computing-provider/constants"
+)
+
+// verifyWalletSignature checks that the signature is the personal_sign (EIP-191) of the message by the wallet
+func verifyWalletSignature(wallet, message, signature string) error {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("invalid signature")
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	hash := crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+	publicKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature, error: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*publicKey).Hex(); !strings.EqualFold(signer, wallet) {
+		return fmt.Errorf("the signature is not signed by the wallet %s", wallet)
+	}
+	return nil
+}
+
+// namespaceWallet returns the wallet owning the namespace of a space
+func namespaceWallet(namespace string) string {
+	return strings.TrimPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX)
+}
@@ -33,15 +33,15 @@ func (s *ScheduleTask) Run() {
 	for {
 		select {
 		case job := <-deployingChan:
-			s.TaskMap.Store(job.Uuid, job.Status)
+			s.TaskMap.Store(job.Uuid, job)
 		case <-time.After(15 * time.Second):
 			s.TaskMap.Range(func(key, value any) bool {
 				jobUuid := key.(string)
-				jobStatus := value.(models.JobStatus)
-				if flag := reportJobStatus(jobUuid, jobStatus); flag {
+				job := value.(models.Job)
+				if flag := reportJobStatus(job); flag {
 					s.TaskMap.Delete(jobUuid)
 				}
-				if jobStatus == models.JobDeployToK8s {
+				if job.Status == models.JobDeployToK8s || job.Status == models.JobDeployFailed || job.Status == models.JobCompleted {
 					s.TaskMap.Delete(jobUuid)
 				}
 				return true
@@ -50,10 +50,13 @@ func (s *ScheduleTask) Run() {
 	}
 }
 
-func reportJobStatus(jobUuid string, jobStatus models.JobStatus) bool {
+func reportJobStatus(job models.Job) bool {
 	reqParam := map[string]interface{}{
-		"job_uuid": jobUuid,
-		"status":   jobStatus,
+		"job_uuid": job.Uuid,
+		"status":   job.Status,
+	}
+	if job.Message != "" {
+		reqParam["message"] = job.Message
 	}
 
 	payload, err := json.Marshal(reqParam)
@@ -82,43 +85,16 @@ func reportJobStatus(jobUuid string, jobStatus models.JobStatus) bool {
 	if resp.StatusCode != http.StatusOK {
 		return true
 	}
-	logs.GetLogger().Infof("report job status successfully. uuid: %s, status: %s", jobUuid, jobStatus)
+	logs.GetLogger().Infof("report job status successfully. uuid: %s, status: %s", job.Uuid, job.Status)
 	return false
 }
 
 func RunSyncTask() {
-	go func() {
-		k8sService := NewK8sService()
-		nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
-		if err != nil {
-			logs.GetLogger().Error(err)
-			return
-		}
-
-		nodeGpuInfoMap, err := k8sService.GetPodLog(context.TODO())
-		if err != nil {
-			logs.GetLogger().Error(err)
-			return
-		}
-
-		for _, node := range nodes.Items {
-			cpNode := node
-			if gpu, ok := nodeGpuInfoMap[cpNode.Name]; ok {
-				var gpuInfo struct {
-					Gpu models.Gpu `json:"gpu"`
-				}
-				if err = json.Unmarshal([]byte(gpu.String()), &gpuInfo); err != nil {
-					logs.GetLogger().Error(err)
-					return
-				}
-				for _, detail := range gpuInfo.Gpu.Details {
-					if err = k8sService.AddNodeLabel(cpNode.Name, detail.ProductName); err != nil {
-						logs.GetLogger().Error(err)
-					}
-				}
-			}
-		}
-	}()
+	if isDockerOrchestrator() {
+		go serveSpaceProxy()
+	} else {
+		go labelGpuNodes()
+	}
 
 	go func() {
 		defer func() {
@@ -155,10 +131,13 @@ func RunSyncTask() {
 		nodeId, _, _ := generateNodeID()
 
 		for range ticker.C {
-			providerStatus, err := checkClusterProviderStatus()
-			if err != nil {
-				logs.GetLogger().Errorf("check cluster resource failed, error: %+v", err)
-				return
+			providerStatus := models.ActiveStatus
+			if !isDockerOrchestrator() {
+				var err error
+				if providerStatus, err = checkClusterProviderStatus(); err != nil {
+					logs.GetLogger().Errorf("check cluster resource failed, error: %+v", err)
+					return
+				}
 			}
 			if providerStatus == models.InactiveStatus {
 				logs.GetLogger().Infof("provider status: %s", providerStatus)
@@ -169,14 +148,52 @@ func RunSyncTask() {
 	}()
 
 	watchExpiredTask()
-	watchNameSpaceForDeleted()
+	if isDockerOrchestrator() {
+		watchReleasedDockerVolumes()
+	} else {
+		watchNameSpaceForDeleted()
+		watchReleasedVolumes()
+	}
 }
 
-func reportClusterResource(location, nodeId string) {
+// labelGpuNodes labels every node of the cluster with the GPU models it has
+func labelGpuNodes() {
 	k8sService := NewK8sService()
-	statisticalSources, err := k8sService.StatisticalSources(context.TODO())
+	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		logs.GetLogger().Error(err)
+		return
+	}
+
+	nodeGpuInfoMap, err := k8sService.GetPodLog(context.TODO())
+	if err != nil {
+		logs.GetLogger().Error(err)
+		return
+	}
+
+	for _, node := range nodes.Items {
+		cpNode := node
+		if gpu, ok := nodeGpuInfoMap[cpNode.Name]; ok {
+			var gpuInfo struct {
+				Gpu models.Gpu `json:"gpu"`
+			}
+			if err = json.Unmarshal([]byte(gpu.String()), &gpuInfo); err != nil {
+				logs.GetLogger().Error(err)
+				return
+			}
+			for _, detail := range gpuInfo.Gpu.Details {
+				if err = k8sService.AddNodeLabel(cpNode.Name, detail.ProductName); err != nil {
+					logs.GetLogger().Error(err)
+				}
+			}
+		}
+	}
+}
+
+func reportClusterResource(location, nodeId string) {
+	statisticalSources, err := getOrchestrator().NodeResources(context.TODO())
 	if err != nil {
-		logs.GetLogger().Errorf("Failed k8s statistical sources, error: %+v", err)
+		logs.GetLogger().Errorf("Failed statistical sources, error: %+v", err)
 		return
 	}
 	clusterSource := models.ClusterResource{
@@ -258,7 +275,7 @@ func watchExpiredTask() {
 						if spaceName != "" {
 							deleteJob(namespace, spaceName)
 						}
-						deleteJob(namespace, spaceUuid)
+						getOrchestrator().Delete(namespace, spaceUuid)
 						deleteKey = append(deleteKey, key)
 					}
 				}
@@ -296,6 +313,14 @@ func watchNameSpaceForDeleted() {
 					continue
 				}
 				if !getPods && strings.HasPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+					retained, err := namespaceRetainsVolumes(namespace)
+					if err != nil {
+						logs.GetLogger().Errorf("Failed check persistent volume claims, namespace: %s, error: %+v", namespace, err)
+						continue
+					}
+					if retained {
+						continue
+					}
 					if err = service.DeleteNameSpace(context.TODO(), namespace); err != nil {
 						logs.GetLogger().Errorf("Failed delete namespace, namepace: %s, error: %+v", namespace, err)
 					}
@@ -305,3 +330,55 @@ func watchNameSpaceForDeleted() {
 		}
 	}()
 }
+
+func watchReleasedDockerVolumes() {
+	ticker := time.NewTicker(10 * time.Minute)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("catch panic error: %+v", err)
+			}
+		}()
+
+		orchestrator := newDockerOrchestrator()
+		for range ticker.C {
+			orchestrator.removeReleasedVolumes()
+		}
+	}()
+}
+
+func watchReleasedVolumes() {
+	ticker := time.NewTicker(10 * time.Minute)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("catch panic error: %+v", err)
+			}
+		}()
+
+		for range ticker.C {
+			service := NewK8sService()
+			volumeClaims, err := service.ListPersistentVolumeClaims(context.TODO(), "")
+			if err != nil {
+				logs.GetLogger().Errorf("Failed get persistent volume claims, error: %+v", err)
+				continue
+			}
+
+			for _, pvc := range volumeClaims {
+				releaseAtStr, ok := pvc.Annotations[constants.K8S_PVC_RELEASE_ANNOTATION]
+				if !ok {
+					continue
+				}
+				releaseAt, err := strconv.ParseInt(releaseAtStr, 10, 64)
+				if err != nil || time.Now().Unix() < releaseAt {
+					continue
+				}
+				if err = service.DeletePersistentVolumeClaim(context.TODO(), pvc.Namespace, pvc.Name); err != nil {
+					logs.GetLogger().Errorf("Failed delete persistent volume claim, namespace: %s, pvc: %s, error: %+v", pvc.Namespace, pvc.Name, err)
+					continue
+				}
+				logs.GetLogger().Infof("Deleted released persistent volume claim, namespace: %s, pvc: %s", pvc.Namespace, pvc.Name)
+			}
+		}
+	}()
+}
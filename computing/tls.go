@@ -0,0 +1,57 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// spaceIngressTLS returns the certificate the ingress of the space is served with, or nil when TLS is not configured.
+// The wildcard certificate is the default certificate of the ingress controller and never leaves its namespace,
+// the copies older versions made in the namespaces of the spaces are removed.
+func spaceIngressTLS(k8sNameSpace, spaceUuid string) (*ingressTLS, error) {
+	tlsConf := conf.GetConfig().TLS
+	if tlsConf.SecretName != "" && k8sNameSpace != tlsConf.SecretNamespace {
+		err := NewK8sService().DeleteSecret(context.TODO(), k8sNameSpace, tlsConf.SecretName)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed delete the copy of tls secret %s, error: %w", tlsConf.SecretName, err)
+		}
+	}
+	return ingressTLSOf(spaceUuid), nil
+}
+
+// ingressTLSOf returns the certificate the ingress of the space references: none with a wildcard certificate, so that
+// the ingress controller serves its default certificate, or the certificate cert-manager issues for the space
+func ingressTLSOf(spaceUuid string) *ingressTLS {
+	tlsConf := conf.GetConfig().TLS
+	if tlsConf.SecretName != "" {
+		return &ingressTLS{}
+	}
+	if tlsConf.ClusterIssuer != "" {
+		return &ingressTLS{
+			SecretName:    constants.K8S_TLS_SECRET_PREFIX + spaceUuid,
+			ClusterIssuer: tlsConf.ClusterIssuer,
+		}
+	}
+	return nil
+}
+
+// releaseSpaceCertificates deletes the certificates cert-manager issued for the workloads of a space whose lease ended,
+// the certificates of its custom domains are released with them
+func releaseSpaceCertificates(namespace, spaceUuid string) {
+	if isDockerOrchestrator() || conf.GetConfig().TLS.ClusterIssuer == "" {
+		return
+	}
+	secretName := constants.K8S_TLS_SECRET_PREFIX + spaceUuid
+	err := NewK8sService().DeleteSecrets(context.TODO(), namespace, func(name string) bool {
+		return name == secretName || strings.HasPrefix(name, secretName+"-")
+	})
+	if err != nil {
+		logs.GetLogger().Errorf("Failed delete tls secrets, spaceUuid: %s, error: %+v", spaceUuid, err)
+	}
+}
@@ -0,0 +1,174 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/lagrangedao/go-computing-provider/conf"
+	"github.com/lagrangedao/go-computing-provider/constants"
+	"github.com/lagrangedao/go-computing-provider/yaml"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	defaultVolumeSize      = "10Gi"
+	defaultVolumeMountPath = "/data"
+	defaultVolumeRetain    = 24 * time.Hour
+)
+
+// spacePersistentVolume returns the persistent volume of the space and where it is mounted, its volume claim is created
+// when missing. It returns nil when the space does not request one or persistent volumes are disabled by the provider.
+func spacePersistentVolume(k8sNameSpace, spaceUuid string, storage *yaml.PersistentStorage) (*coreV1.Volume, []coreV1.VolumeMount, error) {
+	volume, volumeMounts, size, err := persistentVolumeOf(spaceUuid, storage)
+	if volume == nil || err != nil {
+		return nil, nil, err
+	}
+
+	pvc, err := NewK8sService().EnsurePersistentVolumeClaim(context.TODO(), k8sNameSpace, spaceUuid, conf.GetConfig().Volume.StorageClass, size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed create persistent volume claim, error: %w", err)
+	}
+	logs.GetLogger().Infof("Persistent volume claim is ready, namespace: %s, pvc: %s", k8sNameSpace, pvc.Name)
+	return volume, volumeMounts, nil
+}
+
+// persistentVolumeOf returns the persistent volume the space requests, where it is mounted and the size of its claim.
+// Every path of a Dockerfile declaring several volumes is mounted from its own sub path of the one volume.
+func persistentVolumeOf(spaceUuid string, storage *yaml.PersistentStorage) (*coreV1.Volume, []coreV1.VolumeMount, resource.Quantity, error) {
+	if storage == nil {
+		return nil, nil, resource.Quantity{}, nil
+	}
+	volumeConf := conf.GetConfig().Volume
+	if !volumeConf.Enable {
+		logs.GetLogger().Warnf("Persistent volumes are disabled, spaceUuid: %s uses ephemeral storage", spaceUuid)
+		return nil, nil, resource.Quantity{}, nil
+	}
+
+	size := storage.Size
+	if size == "" {
+		size = volumeConf.Size
+	}
+	if size == "" {
+		size = defaultVolumeSize
+	}
+	sizeQuantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, nil, resource.Quantity{}, fmt.Errorf("invalid persistent storage size: %s", size)
+	}
+
+	mountPaths := storage.Paths
+	if len(mountPaths) == 0 {
+		mountPath := storage.Path
+		if mountPath == "" {
+			mountPath = volumeConf.MountPath
+		}
+		if mountPath == "" {
+			mountPath = defaultVolumeMountPath
+		}
+		mountPaths = []string{mountPath}
+	}
+
+	pvcName := constants.K8S_PVC_NAME_PREFIX + spaceUuid
+	volume := &coreV1.Volume{
+		Name: pvcName,
+		VolumeSource: coreV1.VolumeSource{
+			PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{
+				ClaimName: pvcName,
+			},
+		},
+	}
+	var volumeMounts []coreV1.VolumeMount
+	for _, mountPath := range mountPaths {
+		volumeMount := coreV1.VolumeMount{Name: pvcName, MountPath: mountPath}
+		if len(mountPaths) > 1 {
+			subPath := volumeSubPath(mountPath)
+			if subPath == "" {
+				return nil, nil, resource.Quantity{}, fmt.Errorf("invalid persistent storage path: %s", mountPath)
+			}
+			volumeMount.SubPath = subPath
+		}
+		volumeMounts = append(volumeMounts, volumeMount)
+	}
+	return volume, volumeMounts, sizeQuantity, nil
+}
+
+// volumeSubPath returns the sub path of the volume a mount path is kept in, e.g. var/lib/mysql for /var/lib/mysql
+func volumeSubPath(mountPath string) string {
+	subPath := strings.Trim(path.Clean("/"+mountPath), "/")
+	if subPath == "" || strings.HasPrefix(subPath, "..") {
+		return ""
+	}
+	return subPath
+}
+
+// releaseSpaceVolume schedules the persistent volumes of the space, including the ones of the other services
+// of a multi-service space, for deletion once the retain period is over
+func releaseSpaceVolume(namespace, spaceUuid string) {
+	retain := volumeRetain()
+	k8sService := NewK8sService()
+	workloads := []string{spaceUuid}
+	pvcList, err := k8sService.ListPersistentVolumeClaims(context.TODO(), namespace)
+	if err != nil {
+		logs.GetLogger().Errorf("Failed list persistent volume claims, namespace: %s, error: %+v", namespace, err)
+	}
+	for _, pvc := range pvcList {
+		if strings.HasPrefix(pvc.Name, constants.K8S_PVC_NAME_PREFIX+spaceUuid+"-") {
+			workloads = append(workloads, strings.TrimPrefix(pvc.Name, constants.K8S_PVC_NAME_PREFIX))
+		}
+	}
+
+	for _, workload := range workloads {
+		err := k8sService.ReleasePersistentVolumeClaim(context.TODO(), namespace, workload, time.Now().Add(retain))
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				logs.GetLogger().Errorf("Failed release persistent volume claim, spaceUuid: %s, error: %+v", workload, err)
+			}
+			continue
+		}
+		logs.GetLogger().Infof("Released persistent volume claim %s, it will be deleted after %s", constants.K8S_PVC_NAME_PREFIX+workload, retain)
+	}
+}
+
+// volumeRetain returns how long the volume of a space is kept after its lease ended
+func volumeRetain() time.Duration {
+	if hours := conf.GetConfig().Volume.RetainHours; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultVolumeRetain
+}
+
+// namespaceRetainsVolumes tells whether the volume claims of a namespace without pods are still in their retain period.
+// A claim without a release time, left by a failed deployment or created before volumes were released, belongs to no
+// space anymore, it is released now and deleted once the retain period passed.
+func namespaceRetainsVolumes(namespace string) (bool, error) {
+	k8sService := NewK8sService()
+	pvcList, err := k8sService.ListPersistentVolumeClaims(context.TODO(), namespace)
+	if err != nil {
+		return false, err
+	}
+
+	var retained bool
+	for _, pvc := range pvcList {
+		releaseAtStr, ok := pvc.Annotations[constants.K8S_PVC_RELEASE_ANNOTATION]
+		if !ok {
+			workload := strings.TrimPrefix(pvc.Name, constants.K8S_PVC_NAME_PREFIX)
+			if err = k8sService.ReleasePersistentVolumeClaim(context.TODO(), namespace, workload, time.Now().Add(volumeRetain())); err != nil {
+				return false, err
+			}
+			logs.GetLogger().Infof("Released orphaned persistent volume claim %s, it will be deleted after %s", pvc.Name, volumeRetain())
+			retained = true
+			continue
+		}
+		if releaseAt, err := strconv.ParseInt(releaseAtStr, 10, 64); err == nil && time.Now().Unix() < releaseAt {
+			retained = true
+		}
+	}
+	return retained, nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -17,6 +18,20 @@ type ComputeNode struct {
 	LAG      LAG
 	MCS      MCS
 	Registry Registry
+	Deploy   Deploy
+	Volume   Volume
+	Network  Network
+	TLS      TLS
+
+	NetworkPolicy NetworkPolicy
+	WalletQuota   WalletQuota
+	Security      Security
+	Runtime       Runtime
+	Placement     map[string]TierPlacement
+	Batch         Batch
+	Orchestrator  Orchestrator
+	Build         Build
+	Admission     Admission
 }
 
 type API struct {
@@ -47,6 +62,133 @@ type Registry struct {
 	Password      string
 }
 
+type Deploy struct {
+	ReadyTimeout    int // seconds to wait for a space pod to become ready
+	MaxReplicas     int
+	TierMaxReplicas map[string]int
+}
+
+type Volume struct {
+	Enable       bool
+	StorageClass string
+	Size         string
+	MountPath    string
+	RetainHours  int
+}
+
+type Network struct {
+	PublicIp    string
+	ServiceType string
+	NodePortMin int
+	NodePortMax int
+}
+
+type TLS struct {
+	SecretName      string
+	SecretNamespace string
+	ClusterIssuer   string
+}
+
+type NetworkPolicy struct {
+	Enable           bool
+	IngressNamespace string
+	PodCidrs         []string
+	ServiceCidrs     []string
+	DenyEgressCidrs  []string
+	AllowEgressCidrs []string
+}
+
+type WalletQuota struct {
+	Enable                  bool
+	Cpu                     string
+	Memory                  string
+	EphemeralStorage        string
+	Gpus                    map[string]int
+	Pods                    int
+	DefaultCpu              string
+	DefaultMemory           string
+	DefaultEphemeralStorage string
+}
+
+type Security struct {
+	Enable                 bool
+	RunAsUser              int64
+	ReadOnlyRootFilesystem bool
+	WritablePaths          []string
+	PodSecurityLevel       string
+}
+
+type Runtime struct {
+	ClassName        string
+	TierClasses      map[string]string
+	TrustedWallets   []string
+	TrustedClassName string
+}
+
+// TierPlacement is where the spaces of a hardware tier may run: the tier is CPU, a GPU model, or GPU for every GPU model
+type TierPlacement struct {
+	NodeSelector map[string]string
+	NodeAffinity []NodeAffinityRule
+	Tolerations  []Toleration
+	AntiAffinity string // spread the pods of a space over nodes: preferred or required
+}
+
+type NodeAffinityRule struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+type Toleration struct {
+	Key      string
+	Operator string
+	Value    string
+	Effect   string
+}
+
+type Batch struct {
+	CollectorImage string
+}
+
+type Orchestrator struct {
+	Type         string // kubernetes or docker
+	ProxyAddress string
+	ProxyTlsCert string
+	ProxyTlsKey  string
+	HostPortMin  int
+	HostPortMax  int
+}
+
+type Build struct {
+	Builder          string // docker or kaniko
+	Namespace        string
+	KanikoImage      string
+	FetchImage       string
+	PushImage        string
+	KeepImages       int
+	InsecureRegistry bool
+	TimeoutMinutes   int
+	Cpu              string
+	Memory           string
+	MaxContextSize   string
+	NetworkMode      string // default or none, docker only
+	LogLimit         string // the build output kept per job, e.g. 256Ki
+	LogRetainHours   int
+	UploadFailedLog  bool
+}
+
+// Admission is the policy the images of spaces are checked against before they are built or deployed, the images match
+// the patterns by their full name, e.g. docker.io/library/python, or their short name, e.g. python
+type Admission struct {
+	Enable             bool
+	AllowedRegistries  []string
+	DeniedRegistries   []string
+	AllowedImages      []string
+	DeniedImages       []string
+	RequireDigest      bool
+	DeniedInstructions []string
+}
+
 func InitConfig() error {
 	currentDir, _ := os.Getwd()
 	configFile := filepath.Join(currentDir, "config.toml")
@@ -58,6 +200,30 @@ func InitConfig() error {
 			log.Fatal("Required fields not given")
 		}
 	}
+	if err := checkBuild(config.Build); err != nil {
+		return err
+	}
+	return checkNetworkPolicy(config.NetworkPolicy)
+}
+
+// checkBuild rejects the settings the configured builder cannot enforce: kaniko pulls the base images from the
+// container running the RUN steps, their network cannot be removed
+func checkBuild(build Build) error {
+	if strings.EqualFold(build.Builder, "kaniko") && strings.EqualFold(build.NetworkMode, "none") {
+		return fmt.Errorf("Build.NetworkMode none is not supported by the kaniko builder, use the docker builder")
+	}
+	return nil
+}
+
+// checkNetworkPolicy requires the CIDRs of the cluster when the isolation is enabled, without them a space could
+// reach the pods and services of other tenants
+func checkNetworkPolicy(policy NetworkPolicy) error {
+	if !policy.Enable {
+		return nil
+	}
+	if len(policy.PodCidrs) == 0 || len(policy.ServiceCidrs) == 0 {
+		return fmt.Errorf("NetworkPolicy.PodCidrs and NetworkPolicy.ServiceCidrs are required when NetworkPolicy.Enable is set")
+	}
 	return nil
 }
 
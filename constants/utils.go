@@ -17,5 +17,22 @@ const K8S_NAMESPACE_NAME_PREFIX = "ns-"
 const K8S_CONTAINER_NAME_PREFIX = "pod-"
 const K8S_INGRESS_NAME_PREFIX = "ing-"
 const K8S_SERVICE_NAME_PREFIX = "svc-"
+const K8S_EXTERNAL_SERVICE_SUFFIX = "-ext"
 const K8S_DEPLOY_NAME_PREFIX = "deploy-"
+const K8S_PVC_NAME_PREFIX = "pvc-"
+const K8S_HPA_NAME_PREFIX = "hpa-"
+const K8S_JOB_NAME_PREFIX = "job-"
+const K8S_BUILD_NAME_PREFIX = "build-"
+const K8S_REGISTRY_SECRET_NAME = "lagrange-registry"
+const K8S_TLS_SECRET_PREFIX = "tls-"
+const K8S_SECRET_NAME_PREFIX = "secret-"
+const K8S_NETWORK_POLICY_INGRESS = "lagrange-ingress"
+const K8S_NETWORK_POLICY_EGRESS = "lagrange-egress"
+const K8S_WALLET_QUOTA_NAME = "lagrange-wallet-quota"
+const K8S_PVC_RELEASE_ANNOTATION = "lagrange/release-at"
 const REDIS_FULL_PREFIX = "FULL:"
+const REDIS_DOMAIN_PREFIX = "DOMAIN:"
+const REDIS_DOMAIN_OWNER_PREFIX = "DOMAIN_OWNER:"
+const REDIS_BUILD_LOG_PREFIX = "BUILD_LOG:"
+const REDIS_VOLUME_RELEASE_KEY = "VOLUME_RELEASE"
+const DOMAIN_CHALLENGE_PREFIX = "_lagrange-challenge."
@@ -0,0 +1,278 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/lagrangedao/go-computing-provider/conf"
+)
+
+// ContainerSpec describes a container run on the Docker Engine of the host, HealthCmd tells whether it is healthy.
+// User, CapDrop, SecurityOpt, ReadonlyRootfs and Tmpfs harden the container of an untrusted space.
+type ContainerSpec struct {
+	Name       string
+	Image      string
+	Entrypoint []string
+	Cmd        []string
+	Env        []string
+	Labels     map[string]string
+	Network    string
+	Aliases    []string
+	ExtraHosts []string
+	Ports      []ContainerPort
+	Mounts     []mount.Mount
+	NanoCpus   int64
+	Memory     int64
+	Gpus       int
+	Restart    bool
+	HealthCmd  []string
+
+	User           string
+	CapDrop        []string
+	SecurityOpt    []string
+	ReadonlyRootfs bool
+	Tmpfs          map[string]string
+}
+
+// ContainerPort is a port of the container published on the host, on a random port when HostPort is 0
+type ContainerPort struct {
+	Port     int32
+	Protocol string
+	HostIp   string
+	HostPort int32
+}
+
+func (p ContainerPort) natPort() nat.Port {
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return nat.Port(fmt.Sprintf("%d/%s", p.Port, protocol))
+}
+
+// StartContainer pulls the image when it is missing, then creates and starts the container
+func (ds *DockerService) StartContainer(ctx context.Context, spec ContainerSpec) (string, error) {
+	if err := ds.ensureImage(ctx, spec.Image); err != nil {
+		return "", err
+	}
+
+	exposedPorts := nat.PortSet{}
+	portBindings := nat.PortMap{}
+	for _, port := range spec.Ports {
+		hostPort := ""
+		if port.HostPort != 0 {
+			hostPort = strconv.Itoa(int(port.HostPort))
+		}
+		exposedPorts[port.natPort()] = struct{}{}
+		portBindings[port.natPort()] = append(portBindings[port.natPort()], nat.PortBinding{HostIP: port.HostIp, HostPort: hostPort})
+	}
+
+	hostConfig := &container.HostConfig{
+		PortBindings:   portBindings,
+		Mounts:         spec.Mounts,
+		ExtraHosts:     spec.ExtraHosts,
+		CapDrop:        spec.CapDrop,
+		SecurityOpt:    spec.SecurityOpt,
+		ReadonlyRootfs: spec.ReadonlyRootfs,
+		Tmpfs:          spec.Tmpfs,
+		Resources: container.Resources{
+			NanoCPUs: spec.NanoCpus,
+			Memory:   spec.Memory,
+		},
+	}
+	if spec.Restart {
+		hostConfig.RestartPolicy = container.RestartPolicy{Name: "unless-stopped"}
+	}
+	if spec.Gpus > 0 {
+		hostConfig.DeviceRequests = []container.DeviceRequest{{
+			Driver:       "nvidia",
+			Count:        spec.Gpus,
+			Capabilities: [][]string{{"gpu"}},
+		}}
+	}
+
+	var networkingConfig *network.NetworkingConfig
+	if spec.Network != "" {
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				spec.Network: {Aliases: spec.Aliases},
+			},
+		}
+	}
+
+	config := &container.Config{
+		Image:        spec.Image,
+		Entrypoint:   spec.Entrypoint,
+		Cmd:          spec.Cmd,
+		Env:          spec.Env,
+		Labels:       spec.Labels,
+		ExposedPorts: exposedPorts,
+		User:         spec.User,
+	}
+	if len(spec.HealthCmd) > 0 {
+		config.Healthcheck = &container.HealthConfig{
+			Test:     append([]string{"CMD"}, spec.HealthCmd...),
+			Interval: 5 * time.Second,
+			Timeout:  3 * time.Second,
+			Retries:  3,
+		}
+	}
+
+	created, err := ds.c.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, spec.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed create container %s, error: %w", spec.Name, err)
+	}
+	if err = ds.c.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return "", fmt.Errorf("failed start container %s, error: %w", spec.Name, err)
+	}
+	return created.ID, nil
+}
+
+func (ds *DockerService) ensureImage(ctx context.Context, imageName string) error {
+	if _, _, err := ds.c.ImageInspectWithRaw(ctx, imageName); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return err
+	}
+
+	var pullOptions types.ImagePullOptions
+	if registry := conf.GetConfig().Registry; registry.ServerAddress != "" {
+		authConfigBytes, _ := json.Marshal(types.AuthConfig{
+			ServerAddress: registry.ServerAddress,
+			Username:      registry.UserName,
+			Password:      registry.Password,
+		})
+		pullOptions.RegistryAuth = base64.URLEncoding.EncodeToString(authConfigBytes)
+	}
+	rd, err := ds.c.ImagePull(ctx, imageName, pullOptions)
+	if err != nil {
+		return fmt.Errorf("failed pull image %s, error: %w", imageName, err)
+	}
+	defer rd.Close()
+	return printOut(rd, io.Discard)
+}
+
+// WaitContainer waits for the container to exit and returns its exit code
+func (ds *DockerService) WaitContainer(ctx context.Context, containerId string) (int64, error) {
+	statusCh, errCh := ds.c.ContainerWait(ctx, containerId, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		if status.Error != nil {
+			return status.StatusCode, errors.New(status.Error.Message)
+		}
+		return status.StatusCode, nil
+	case err := <-errCh:
+		return 0, err
+	}
+}
+
+// ListContainers returns the containers, running or not, having all the labels
+func (ds *DockerService) ListContainers(ctx context.Context, labels map[string]string) ([]types.Container, error) {
+	return ds.c.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: labelFilters(labels)})
+}
+
+// labelFilters matches the objects having all the labels, a label with an empty value matches any value
+func labelFilters(labels map[string]string) filters.Args {
+	args := filters.NewArgs()
+	for key, value := range labels {
+		if value == "" {
+			args.Add("label", key)
+		} else {
+			args.Add("label", key+"="+value)
+		}
+	}
+	return args
+}
+
+// InspectContainer returns the state and the published ports of the container
+func (ds *DockerService) InspectContainer(ctx context.Context, containerId string) (types.ContainerJSON, error) {
+	return ds.c.ContainerInspect(ctx, containerId)
+}
+
+// RemoveContainers removes the containers having all the labels, running or not
+func (ds *DockerService) RemoveContainers(ctx context.Context, labels map[string]string) error {
+	containers, err := ds.ListContainers(ctx, labels)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if err = ds.c.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed remove container %s, error: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// ContainerLogs returns the last lines the container wrote to stdout and stderr
+func (ds *DockerService) ContainerLogs(ctx context.Context, containerId string, tailLines int64) (string, error) {
+	rd, err := ds.c.ContainerLogs(ctx, containerId, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.FormatInt(tailLines, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer rd.Close()
+
+	var out bytes.Buffer
+	if _, err = stdcopy.StdCopy(&out, &out, rd); err != nil && err != io.EOF {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// EnsureNetwork creates the bridge network when it does not exist
+func (ds *DockerService) EnsureNetwork(ctx context.Context, name string, labels map[string]string) error {
+	if _, err := ds.c.NetworkInspect(ctx, name, types.NetworkInspectOptions{}); err == nil {
+		return nil
+	}
+	_, err := ds.c.NetworkCreate(ctx, name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels:         labels,
+	})
+	return err
+}
+
+func (ds *DockerService) RemoveNetwork(ctx context.Context, name string) error {
+	err := ds.c.NetworkRemove(ctx, name)
+	if err != nil && client.IsErrNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// RemoveVolumes removes the volumes having all the labels
+func (ds *DockerService) RemoveVolumes(ctx context.Context, labels map[string]string) error {
+	volumes, err := ds.c.VolumeList(ctx, labelFilters(labels))
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes.Volumes {
+		if err = ds.c.VolumeRemove(ctx, v.Name, true); err != nil && !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed remove volume %s, error: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// Info returns the CPUs and the memory of the host
+func (ds *DockerService) Info(ctx context.Context) (types.Info, error) {
+	return ds.c.Info(ctx)
+}
@@ -17,6 +17,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -41,29 +43,173 @@ func NewDockerService() *DockerService {
 }
 
 func ExtractExposedPort(dockerfilePath string) (string, error) {
+	exposedPorts, err := ExtractExposedPorts(dockerfilePath)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(exposedPorts[0].Port), nil
+}
+
+// ExposedPort is a port declared by an EXPOSE instruction, Protocol is tcp or udp
+type ExposedPort struct {
+	Port     int
+	Protocol string
+}
+
+// MaxExposedPorts is the number of ports a Dockerfile can expose, each one gets a host name of its own
+const MaxExposedPorts = 16
+
+// ExtractExposedPorts returns every port declared by the EXPOSE instructions of a Dockerfile, in order, it fails when
+// they declare more than MaxExposedPorts ports
+func ExtractExposedPorts(dockerfilePath string) ([]ExposedPort, error) {
+	instructions, err := ParseDockerfile(dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var exposedPorts []ExposedPort
+	seen := make(map[ExposedPort]bool)
+	re := regexp.MustCompile(`^(\d+)(?:-(\d+))?(?:/(tcp|udp))?$`)
+	for _, instruction := range instructions {
+		if instruction.Cmd != "EXPOSE" {
+			continue
+		}
+		for _, field := range instruction.Args {
+			match := re.FindStringSubmatch(strings.ToLower(field))
+			if match == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(match[1])
+			end := start
+			if match[2] != "" {
+				end, _ = strconv.Atoi(match[2])
+			}
+			if start < 1 || end > 65535 || end < start {
+				return nil, fmt.Errorf("invalid EXPOSE port: %s", field)
+			}
+			if end-start >= MaxExposedPorts {
+				return nil, fmt.Errorf("the EXPOSE port range %s is larger than %d ports", field, MaxExposedPorts)
+			}
+			protocol := match[3]
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			for port := start; port <= end; port++ {
+				exposedPort := ExposedPort{Port: port, Protocol: protocol}
+				if !seen[exposedPort] {
+					seen[exposedPort] = true
+					exposedPorts = append(exposedPorts, exposedPort)
+				}
+				if len(exposedPorts) > MaxExposedPorts {
+					return nil, fmt.Errorf("the Dockerfile exposes more than %d ports", MaxExposedPorts)
+				}
+			}
+		}
+	}
+
+	if len(exposedPorts) == 0 {
+		return nil, fmt.Errorf("no exposed port found in Dockerfile")
+	}
+	return exposedPorts, nil
+}
+
+// HealthCheck is the HEALTHCHECK instruction declared in a Dockerfile
+type HealthCheck struct {
+	Command     []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// ExtractHealthCheck returns the last HEALTHCHECK declared in the Dockerfile, or nil if there is none
+func ExtractHealthCheck(dockerfilePath string) (*HealthCheck, error) {
 	file, err := os.Open(dockerfilePath)
 	if err != nil {
-		return "", fmt.Errorf("unable to open Dockerfile: %v", err)
+		return nil, fmt.Errorf("unable to open Dockerfile: %v", err)
 	}
 	defer file.Close()
 
+	var healthCheck *HealthCheck
+	var instruction string
 	scanner := bufio.NewScanner(file)
-	exposedPort := ""
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(strings.TrimSpace(line), "EXPOSE") {
-			re := regexp.MustCompile(`\d+`)
-			exposedPort = re.FindString(line)
-			break
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, "\\") {
+			instruction += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		instruction += line
+		fields := strings.Fields(instruction)
+		instruction = ""
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "HEALTHCHECK") {
+			continue
+		}
+		if strings.EqualFold(fields[1], "NONE") {
+			healthCheck = nil
+			continue
+		}
+
+		hc := &HealthCheck{}
+		var i int
+		for i = 1; i < len(fields) && strings.HasPrefix(fields[i], "--"); i++ {
+			option := strings.SplitN(strings.TrimPrefix(fields[i], "--"), "=", 2)
+			if len(option) != 2 {
+				continue
+			}
+			switch option[0] {
+			case "interval":
+				hc.Interval, _ = time.ParseDuration(option[1])
+			case "timeout":
+				hc.Timeout, _ = time.ParseDuration(option[1])
+			case "start-period":
+				hc.StartPeriod, _ = time.ParseDuration(option[1])
+			case "retries":
+				fmt.Sscanf(option[1], "%d", &hc.Retries)
+			}
 		}
+		if i >= len(fields) || !strings.EqualFold(fields[i], "CMD") {
+			continue
+		}
+		cmd := strings.TrimSpace(strings.Join(fields[i+1:], " "))
+		if strings.HasPrefix(cmd, "[") {
+			if err = json.Unmarshal([]byte(cmd), &hc.Command); err != nil {
+				return nil, fmt.Errorf("invalid HEALTHCHECK command: %s", cmd)
+			}
+		} else {
+			hc.Command = []string{"/bin/sh", "-c", cmd}
+		}
+		healthCheck = hc
 	}
+	return healthCheck, scanner.Err()
+}
 
-	if exposedPort == "" {
-		return "", fmt.Errorf("no exposed port found in Dockerfile")
+// ExtractVolumes returns the paths declared by the VOLUME instructions of a Dockerfile, continuation lines included
+func ExtractVolumes(dockerfilePath string) ([]string, error) {
+	instructions, err := ParseDockerfile(dockerfilePath)
+	if err != nil {
+		return nil, err
 	}
 
-	return exposedPort, nil
+	var volumes []string
+	for _, instruction := range instructions {
+		if instruction.Cmd != "VOLUME" || len(instruction.Args) == 0 {
+			continue
+		}
+		args := strings.Join(instruction.Args, " ")
+		if strings.HasPrefix(args, "[") {
+			var paths []string
+			if err = json.Unmarshal([]byte(args), &paths); err != nil {
+				return nil, fmt.Errorf("invalid VOLUME instruction at line %d: %s", instruction.Line, args)
+			}
+			volumes = append(volumes, paths...)
+		} else {
+			volumes = append(volumes, instruction.Args...)
+		}
+	}
+	return volumes, nil
 }
+
 func RunContainer(imageName, dockerfilePath string) string {
 	exposedPort, err := ExtractExposedPort(dockerfilePath)
 	if err != nil {
@@ -163,13 +309,50 @@ func RemoveContainerIfExists(imageName string) error {
 	return nil
 }
 
-func (ds *DockerService) BuildImage(buildPath, imageName string) error {
-	// Create a buffer
+// BuildLimits bounds the resources of the containers running the steps of a build, zero values are unlimited
+type BuildLimits struct {
+	NanoCpus    int64
+	Memory      int64
+	NetworkMode string
+}
+
+// BuildImage builds the image from the files under the build path within the limits and writes the build output to out,
+// the build is cancelled with ctx
+func (ds *DockerService) BuildImage(ctx context.Context, buildPath, imageName string, limits BuildLimits, out io.Writer) error {
 	buf := new(bytes.Buffer)
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
+	if err := ArchiveBuildContext(buildPath, buf); err != nil {
+		return fmt.Errorf("failed archive the build context, error: %w", err)
+	}
+
+	options := types.ImageBuildOptions{
+		Tags:        []string{imageName},
+		Remove:      true,
+		ForceRemove: true,
+		NetworkMode: limits.NetworkMode,
+	}
+	if limits.NanoCpus > 0 {
+		options.CPUPeriod = 100000
+		options.CPUQuota = limits.NanoCpus * options.CPUPeriod / 1e9
+	}
+	if limits.Memory > 0 {
+		options.Memory = limits.Memory
+		options.MemorySwap = limits.Memory
+	}
+
+	dockerFileTarReader := bytes.NewReader(buf.Bytes())
+	options.Context = dockerFileTarReader
+	buildResponse, err := ds.c.ImageBuild(ctx, dockerFileTarReader, options)
+	if err != nil {
+		return err
+	}
+	defer buildResponse.Body.Close()
+	return printOut(buildResponse.Body, out)
+}
 
-	filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
+// ArchiveBuildContext writes the files under the build path to w as a tar archive, with paths relative to the build path
+func ArchiveBuildContext(buildPath string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -197,17 +380,10 @@ func (ds *DockerService) BuildImage(buildPath, imageName string) error {
 		}
 		return nil
 	})
-
-	dockerFileTarReader := bytes.NewReader(buf.Bytes())
-	buildResponse, err := ds.c.ImageBuild(context.Background(), dockerFileTarReader, types.ImageBuildOptions{
-		Context: dockerFileTarReader,
-		Tags:    []string{imageName},
-	})
 	if err != nil {
 		return err
 	}
-	defer buildResponse.Body.Close()
-	return printOut(buildResponse.Body)
+	return tw.Close()
 }
 
 type ErrorLine struct {
@@ -217,7 +393,17 @@ type ErrorLine struct {
 	} `json:"errorDetail"`
 }
 
-func (ds *DockerService) PushImage(imagesName string) error {
+// streamLine is a line of the JSON stream the Docker daemon answers builds, pushes and pulls with
+type streamLine struct {
+	ErrorLine
+	Stream   string `json:"stream"`
+	Status   string `json:"status"`
+	Id       string `json:"id"`
+	Progress string `json:"progress"`
+}
+
+// PushImage pushes the image to the Registry, retrying on failure, and writes the push output to out
+func (ds *DockerService) PushImage(imagesName string, out io.Writer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*6000)
 	defer cancel()
 
@@ -236,7 +422,7 @@ func (ds *DockerService) PushImage(imagesName string) error {
 	for i := 0; i < retries; i++ {
 		rd, rerr := ds.c.ImagePush(ctx, imagesName, opts)
 		if rerr == nil {
-			err = printOut(rd)
+			err = printOut(rd, out)
 			rd.Close()
 			if err == nil {
 				return nil
@@ -244,22 +430,40 @@ func (ds *DockerService) PushImage(imagesName string) error {
 		} else {
 			err = rerr
 		}
+		fmt.Fprintf(out, "push attempt %d failed: %v\n", i+1, err)
 		time.Sleep(2 * time.Second)
 	}
-	return nil
+	return err
 }
 
-func printOut(rd io.Reader) error {
-	var lastLine string
+// printOut writes the messages of the JSON stream of the Docker daemon to out as plain lines, without the progress bars,
+// and returns the error the stream ends with
+func printOut(rd io.Reader, out io.Writer) error {
+	var errLine string
 	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		lastLine = scanner.Text()
-		println(scanner.Text())
+		line := &streamLine{}
+		if err := json.Unmarshal(scanner.Bytes(), line); err != nil {
+			fmt.Fprintln(out, scanner.Text())
+			continue
+		}
+		switch {
+		case line.Error != "":
+			errLine = line.Error
+			fmt.Fprintln(out, line.Error)
+		case line.Stream != "":
+			io.WriteString(out, line.Stream)
+		case line.Status != "" && line.Progress == "":
+			if line.Id != "" {
+				fmt.Fprintf(out, "%s: %s\n", line.Id, line.Status)
+			} else {
+				fmt.Fprintln(out, line.Status)
+			}
+		}
 	}
-	errLine := &ErrorLine{}
-	json.Unmarshal([]byte(lastLine), errLine)
-	if errLine.Error != "" {
-		return errors.New(errLine.Error)
+	if errLine != "" {
+		return errors.New(errLine)
 	}
 	if err := scanner.Err(); err != nil {
 		return err
@@ -300,6 +504,40 @@ func (ds *DockerService) RemoveImage(imageId string) error {
 	return err
 }
 
+// PruneRepositoryImages removes the local tags of the repository but the keep most recently created ones, a tag used
+// by a container is kept
+func (ds *DockerService) PruneRepositoryImages(ctx context.Context, repository string, keep int) error {
+	imageList, err := ds.c.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed list images, error: %w", err)
+	}
+
+	type repositoryTag struct {
+		tag     string
+		created int64
+	}
+	var tags []repositoryTag
+	for _, image := range imageList {
+		for _, tag := range image.RepoTags {
+			if strings.HasPrefix(tag, repository+":") {
+				tags = append(tags, repositoryTag{tag: tag, created: image.Created})
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].created > tags[j].created
+	})
+
+	for i := keep; i < len(tags); i++ {
+		if _, err := ds.c.ImageRemove(ctx, tags[i].tag, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+			logs.GetLogger().Warnf("Failed remove image %s, error: %v", tags[i].tag, err)
+			continue
+		}
+		logs.GetLogger().Infof("Removed image %s", tags[i].tag)
+	}
+	return nil
+}
+
 func (ds *DockerService) CleanResource() {
 	ctx := context.Background()
 	danglingFilters := filters.NewArgs()
@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Instruction is an instruction of a Dockerfile, with its continuation lines joined
+type Instruction struct {
+	Cmd  string
+	Args []string
+	Line int
+}
+
+// ParseDockerfile returns the instructions of a Dockerfile in order, the comments and the blank lines are skipped
+func ParseDockerfile(dockerfilePath string) ([]Instruction, error) {
+	file, err := os.Open(dockerfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open Dockerfile: %v", err)
+	}
+	defer file.Close()
+
+	var instructions []Instruction
+	var current strings.Builder
+	var startLine, lineNo int
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current.Len() == 0 {
+			startLine = lineNo
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		if fields := strings.Fields(current.String()); len(fields) > 0 {
+			instructions = append(instructions, Instruction{Cmd: strings.ToUpper(fields[0]), Args: fields[1:], Line: startLine})
+		}
+		current.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(current.String()); len(fields) > 0 {
+		instructions = append(instructions, Instruction{Cmd: strings.ToUpper(fields[0]), Args: fields[1:], Line: startLine})
+	}
+	return instructions, nil
+}
+
+// ExtractBaseImages returns the images the stages of a Dockerfile start from and the images files are copied from,
+// the references to earlier stages and scratch are left out. The ARG defaults declared before the first FROM are
+// expanded, the images with an undefined variable are returned unexpanded.
+func ExtractBaseImages(dockerfilePath string) ([]string, error) {
+	instructions, err := ParseDockerfile(dockerfilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make(map[string]string)
+	stages := make(map[string]bool)
+	var stageCount int
+	var images []string
+	addImage := func(image string) {
+		expanded, resolved := expandArgs(image, args)
+		if !resolved {
+			expanded = image
+		}
+		if stages[strings.ToLower(expanded)] || strings.EqualFold(expanded, "scratch") {
+			return
+		}
+		images = append(images, expanded)
+	}
+
+	for _, instruction := range instructions {
+		switch instruction.Cmd {
+		case "ARG":
+			if stageCount == 0 {
+				for _, arg := range instruction.Args {
+					name, value, _ := strings.Cut(arg, "=")
+					args[name] = strings.Trim(value, `"'`)
+				}
+			}
+		case "FROM":
+			var params []string
+			for _, arg := range instruction.Args {
+				if !strings.HasPrefix(arg, "--") {
+					params = append(params, arg)
+				}
+			}
+			if len(params) == 0 {
+				continue
+			}
+			addImage(params[0])
+			if len(params) == 3 && strings.EqualFold(params[1], "AS") {
+				stages[strings.ToLower(params[2])] = true
+			}
+			stages[fmt.Sprint(stageCount)] = true
+			stageCount++
+		case "COPY":
+			for _, arg := range instruction.Args {
+				if strings.HasPrefix(arg, "--from=") {
+					addImage(strings.TrimPrefix(arg, "--from="))
+				}
+			}
+		}
+	}
+	return images, nil
+}
+
+// expandArgs replaces the $VAR and ${VAR} references by the values of args, it tells whether all of them were defined
+func expandArgs(s string, args map[string]string) (string, bool) {
+	resolved := true
+	expanded := os.Expand(s, func(name string) string {
+		value, ok := args[name]
+		if !ok || value == "" {
+			resolved = false
+		}
+		return value
+	})
+	return expanded, resolved
+}
@@ -0,0 +1,196 @@
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/client"
+	"github.com/lagrangedao/go-computing-provider/conf"
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// ImageExists tells whether the image is in the local image store of the Docker daemon
+func (ds *DockerService) ImageExists(ctx context.Context, imageName string) (bool, error) {
+	_, _, err := ds.c.ImageInspectWithRaw(ctx, imageName)
+	if err == nil {
+		return true, nil
+	}
+	if client.IsErrNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// RegistryHasImage tells whether the registry serves the manifest of the image, it authenticates with the credentials
+// of Registry and falls back to plain HTTP when Build.InsecureRegistry is set
+func RegistryHasImage(imageName string) (bool, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return false, fmt.Errorf("invalid image name %s, error: %w", imageName, err)
+	}
+	tag := "latest"
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+
+	registry := conf.GetConfig().Registry
+	httpClient, schemes := registryClient()
+
+	var lastErr error
+	for _, scheme := range schemes {
+		url := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, reference.Domain(named), reference.Path(named), tag)
+		req, err := http.NewRequest(http.MethodHead, url, nil)
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if registry.UserName != "" {
+			req.SetBasicAuth(registry.UserName, registry.Password)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return true, nil
+		case http.StatusNotFound:
+			return false, nil
+		default:
+			return false, fmt.Errorf("registry responded %s to %s", resp.Status, url)
+		}
+	}
+	return false, lastErr
+}
+
+// RepositoryPushAuth returns the docker config auth pushing the image: a bearer token the registry scopes to the
+// repository of the image when it uses token authentication, the credentials of Registry otherwise. The token expires
+// after the lifetime the registry gives it, usually minutes.
+func RepositoryPushAuth(imageName string) (map[string]string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image name %s, error: %w", imageName, err)
+	}
+
+	registry := conf.GetConfig().Registry
+	basicAuth := map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte(registry.UserName + ":" + registry.Password))}
+	httpClient, schemes := registryClient()
+	var lastErr error
+	for _, scheme := range schemes {
+		resp, err := httpClient.Get(fmt.Sprintf("%s://%s/v2/", scheme, reference.Domain(named)))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return basicAuth, nil
+		}
+
+		params := parseAuthChallenge(challenge[len("bearer "):])
+		scope := fmt.Sprintf("repository:%s:pull,push", reference.Path(named))
+		token, err := fetchRegistryToken(httpClient, params["realm"], params["service"], scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed get the push token of %s, error: %w", reference.Path(named), err)
+		}
+		return map[string]string{"registrytoken": token}, nil
+	}
+	return nil, lastErr
+}
+
+func fetchRegistryToken(httpClient *http.Client, realm, service, scope string) (string, error) {
+	if realm == "" {
+		return "", fmt.Errorf("the registry gave no token realm")
+	}
+	req, err := http.NewRequest(http.MethodGet, realm, nil)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{"scope": {scope}}
+	if service != "" {
+		query.Set("service", service)
+	}
+	req.URL.RawQuery = query.Encode()
+	if registry := conf.GetConfig().Registry; registry.UserName != "" {
+		req.SetBasicAuth(registry.UserName, registry.Password)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token server responded %s", resp.Status)
+	}
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	if tokenResp.AccessToken != "" {
+		return tokenResp.AccessToken, nil
+	}
+	return "", fmt.Errorf("token server returned no token")
+}
+
+// parseAuthChallenge parses the key="value" parameters of a WWW-Authenticate challenge
+func parseAuthChallenge(params string) map[string]string {
+	result := make(map[string]string)
+	for params != "" {
+		key, rest, found := strings.Cut(strings.TrimLeft(params, " ,"), "=")
+		if !found {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		result[strings.ToLower(strings.TrimSpace(key))] = value
+		params = rest
+	}
+	return result
+}
+
+// registryClient returns the client of the registry and the schemes it is tried with, plain HTTP is tried after HTTPS
+// when Build.InsecureRegistry is set
+func registryClient() (*http.Client, []string) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	schemes := []string{"https"}
+	if conf.GetConfig().Build.InsecureRegistry {
+		httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		schemes = append(schemes, "http")
+	}
+	return httpClient, schemes
+}
@@ -35,8 +35,9 @@ type JobData struct {
 }
 
 type Job struct {
-	Uuid   string
-	Status JobStatus
+	Uuid    string
+	Status  JobStatus
+	Message string
 }
 
 type JobStatus string
@@ -47,9 +48,53 @@ const (
 	JobBuildImage     JobStatus = "buildImage"     // build images
 	JobPushImage      JobStatus = "pushImage"      // push image to registry
 	JobPullImage      JobStatus = "pullImage"      // download file form job_resource_uri
-	JobDeployToK8s    JobStatus = "deployToK8s"    // deploy image to k8s
+	JobDeployToK8s    JobStatus = "deployToK8s"    // deploy image to k8s, the pod is ready
+	JobDeployFailed   JobStatus = "deployFailed"   // the job failed, the reason is reported as message
+	JobCompleted      JobStatus = "completed"      // the batch job ran to completion, the url of its result is reported as message
 )
 
+// JobRecord is the deployment state of a job kept by the provider while its lease is active
+type JobRecord struct {
+	JobUuid    string        `json:"job_uuid"`
+	SpaceUuid  string        `json:"space_uuid"`
+	Namespace  string        `json:"k8s_namespace"`
+	HostName   string        `json:"host_name"`
+	ExpireTime int64         `json:"expire_time"`
+	Ports      []PortMapping `json:"ports"`
+	ResultCid  string        `json:"result_cid,omitempty"`
+	ResultUrl  string        `json:"result_url,omitempty"`
+}
+
+// BuildLog is the output of the image build and push of a job
+type BuildLog struct {
+	JobUuid   string `json:"job_uuid"`
+	Log       string `json:"log"`
+	LogUrl    string `json:"log_url,omitempty"`
+	Truncated bool   `json:"truncated"`
+	Failed    bool   `json:"failed"`
+}
+
+type CustomDomain struct {
+	Domain   string `json:"domain"`
+	Token    string `json:"token"`
+	Verified bool   `json:"verified"`
+}
+
+// DomainChallenge tells the owner of a custom domain which DNS records prove ownership and route it to the space
+type DomainChallenge struct {
+	Domain      string `json:"domain"`
+	RecordName  string `json:"txt_record_name"`
+	RecordValue string `json:"txt_record_value"`
+	CName       string `json:"cname"`
+	Verified    bool   `json:"verified"`
+}
+
+type PortMapping struct {
+	ContainerPort int32  `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	Url           string `json:"url"`
+}
+
 type DeleteJobReq struct {
 	CreatorWallet string `json:"creator_wallet"`
 	SpaceName     string `json:"space_name"`
@@ -91,9 +136,21 @@ type Resource struct {
 	Memory  Specification
 	Gpu     Specification
 	Storage Specification
+
+	GpuResource string // the extended resource the GPUs are requested with, e.g. nvidia.com/gpu or nvidia.com/mig-1g.10gb
 }
 
 type Specification struct {
 	Quantity int64
 	Unit     string
 }
+
+// SpaceContainer is the state of a container of a running space
+type SpaceContainer struct {
+	Name     string `json:"name"`
+	Workload string `json:"workload"`
+	State    string `json:"state"`
+	Ready    bool   `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	Message  string `json:"message,omitempty"`
+}
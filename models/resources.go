@@ -17,10 +17,19 @@ type NodeResource struct {
 }
 
 type Gpu struct {
-	DriverVersion string      `json:"driver_version"`
-	CudaVersion   string      `json:"cuda_version"`
-	AttachedGpus  int         `json:"attached_gpus"`
-	Details       []GpuDetail `json:"details"`
+	DriverVersion string         `json:"driver_version"`
+	CudaVersion   string         `json:"cuda_version"`
+	AttachedGpus  int            `json:"attached_gpus"`
+	Details       []GpuDetail    `json:"details"`
+	Partitions    []GpuPartition `json:"partitions,omitempty"`
+}
+
+// GpuPartition is a MIG profile or a time-sliced share of GPUs a node exposes as an extended resource
+type GpuPartition struct {
+	Resource string `json:"resource"`
+	Total    int64  `json:"total"`
+	Used     int64  `json:"used"`
+	Free     int64  `json:"free"`
 }
 
 type GpuDetail struct {
@@ -67,3 +76,17 @@ type Quota struct {
 	Quota int64  `json:"quota"`
 	Unit  string `json:"unit"`
 }
+
+// HardwareCatalog describes the accelerators the provider can run, and how they are named and requested
+type HardwareCatalog struct {
+	Accelerators []Accelerator `json:"accelerators"`
+}
+
+type Accelerator struct {
+	Vendor          string   `json:"vendor"`           // the vendor name GPU models are normalized to, e.g. NVIDIA
+	Aliases         []string `json:"aliases"`          // other spellings of the vendor in hardware descriptions, e.g. Nvidia
+	Resource        string   `json:"resource"`         // the extended resource of a whole GPU, e.g. nvidia.com/gpu
+	PartitionPrefix string   `json:"partition_prefix"` // the prefix of the extended resources of GPU partitions, e.g. nvidia.com/mig-
+	SharedResource  string   `json:"shared_resource"`  // the extended resource of time-sliced GPU shares, e.g. nvidia.com/gpu.shared
+	NodeLabel       string   `json:"node_label"`       // the node label holding the GPU model, a label named after the model is used when empty
+}
@@ -9,8 +9,16 @@ func CPManager(router *gin.RouterGroup) {
 
 	router.GET("/host/info", computing.GetServiceProviderInfo)
 	router.POST("/lagrange/jobs", computing.ReceiveJob)
+	router.GET("/lagrange/jobs", computing.GetJob)
 	router.POST("/lagrange/jobs/redeploy", computing.RedeployJob)
 	router.DELETE("/lagrange/jobs", computing.DeleteJob)
 	router.GET("/cp", computing.StatisticalSources)
 	router.POST("/lagrange/jobs/renew", computing.ReNewJob)
+	router.POST("/lagrange/jobs/render", computing.RenderJob)
+	router.GET("/lagrange/jobs/status", computing.GetJobStatus)
+	router.GET("/lagrange/jobs/logs", computing.GetJobLogs)
+	router.GET("/lagrange/jobs/build_log", computing.GetJobBuildLog)
+	router.POST("/lagrange/jobs/domain", computing.AddCustomDomain)
+	router.POST("/lagrange/jobs/domain/verify", computing.VerifyCustomDomain)
+	router.DELETE("/lagrange/jobs/domain", computing.DeleteCustomDomain)
 }
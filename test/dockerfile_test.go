@@ -0,0 +1,145 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lagrangedao/go-computing-provider/docker"
+)
+
+func writeDockerfile(t *testing.T, content string) string {
+	dockerfilePath := filepath.Join(t.TempDir(), "Dockerfile")
+	if err := os.WriteFile(dockerfilePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dockerfilePath
+}
+
+func TestExtractHealthCheck(t *testing.T) {
+	dockerfilePath := writeDockerfile(t, `FROM python:3.10
+EXPOSE 7860
+HEALTHCHECK --interval=15s --timeout=5s \
+    --start-period=1m --retries=4 CMD ["curl", "-f", "http://localhost:7860/"]
+CMD ["python", "app.py"]
+`)
+	healthCheck, err := docker.ExtractHealthCheck(dockerfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &docker.HealthCheck{
+		Command:     []string{"curl", "-f", "http://localhost:7860/"},
+		Interval:    15 * time.Second,
+		Timeout:     5 * time.Second,
+		StartPeriod: time.Minute,
+		Retries:     4,
+	}
+	if !reflect.DeepEqual(healthCheck, want) {
+		t.Fatalf("got %+v, want %+v", healthCheck, want)
+	}
+
+	dockerfilePath = writeDockerfile(t, "FROM nginx\nHEALTHCHECK CMD curl -f http://localhost/ || exit 1\n")
+	healthCheck, err = docker.ExtractHealthCheck(dockerfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/bin/sh", "-c", "curl -f http://localhost/ || exit 1"}; !reflect.DeepEqual(healthCheck.Command, want) {
+		t.Fatalf("got %v, want %v", healthCheck.Command, want)
+	}
+
+	dockerfilePath = writeDockerfile(t, "FROM nginx\nHEALTHCHECK CMD true\nHEALTHCHECK NONE\n")
+	if healthCheck, err = docker.ExtractHealthCheck(dockerfilePath); err != nil || healthCheck != nil {
+		t.Fatalf("got %+v, %v, want no health check", healthCheck, err)
+	}
+}
+
+func TestExtractVolumes(t *testing.T) {
+	dockerfilePath := writeDockerfile(t, "FROM python:3.10\nVOLUME [\"/data\", \\\n  \"/models\"]\nVOLUME /cache \\\n  /tmp/work\n")
+	volumes, err := docker.ExtractVolumes(dockerfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/data", "/models", "/cache", "/tmp/work"}; !reflect.DeepEqual(volumes, want) {
+		t.Fatalf("got %v, want %v", volumes, want)
+	}
+}
+
+func TestExtractExposedPorts(t *testing.T) {
+	dockerfilePath := writeDockerfile(t, "FROM node:18\nEXPOSE 3000\nEXPOSE 9090/tcp \\\n  27015/udp 8000-8001\n")
+	exposedPorts, err := docker.ExtractExposedPorts(dockerfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []docker.ExposedPort{
+		{Port: 3000, Protocol: "tcp"},
+		{Port: 9090, Protocol: "tcp"},
+		{Port: 27015, Protocol: "udp"},
+		{Port: 8000, Protocol: "tcp"},
+		{Port: 8001, Protocol: "tcp"},
+	}
+	if !reflect.DeepEqual(exposedPorts, want) {
+		t.Fatalf("got %+v, want %+v", exposedPorts, want)
+	}
+
+	exposedPort, err := docker.ExtractExposedPort(dockerfilePath)
+	if err != nil || exposedPort != "3000" {
+		t.Fatalf("got %s, %v, want 3000", exposedPort, err)
+	}
+}
+
+func TestExtractExposedPortsLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		expose  string
+		wantErr bool
+	}{
+		{name: "range within the limit", expose: "EXPOSE 8000-8015"},
+		{name: "range beyond the limit", expose: "EXPOSE 8000-9000", wantErr: true},
+		{name: "too many ports", expose: "EXPOSE 8000-8015 9000", wantErr: true},
+		{name: "reversed range", expose: "EXPOSE 9000-8000", wantErr: true},
+		{name: "out of range port", expose: "EXPOSE 70000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := docker.ExtractExposedPorts(writeDockerfile(t, "FROM node:18\n"+tt.expose+"\n"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractBaseImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		want       []string
+	}{
+		{name: "single stage", dockerfile: "FROM python:3.11\nRUN pip install flask\n", want: []string{"python:3.11"}},
+		{name: "platform flag", dockerfile: "FROM --platform=linux/amd64 node:18\n", want: []string{"node:18"}},
+		{name: "stages and copy from an image", want: []string{"golang:1.19", "nginx:1.25"},
+			dockerfile: "FROM golang:1.19 AS build\nRUN go build\nFROM nginx:1.25\nCOPY --from=build /app /app\nCOPY --from=0 /bin /bin\n"},
+		{name: "copy from another image", want: []string{"alpine:3.18", "busybox:1.36"},
+			dockerfile: "FROM alpine:3.18\nCOPY --from=busybox:1.36 /bin/busybox /bin/busybox\n"},
+		{name: "scratch is left out", dockerfile: "FROM golang:1.19 AS build\nFROM scratch\nCOPY --from=build /app /app\n",
+			want: []string{"golang:1.19"}},
+		{name: "arg defaults are expanded", dockerfile: "ARG VERSION=3.11\nARG BASE=python\nFROM ${BASE}:$VERSION\n",
+			want: []string{"python:3.11"}},
+		{name: "undefined args are kept", dockerfile: "ARG VERSION\nFROM python:${VERSION}\n", want: []string{"python:${VERSION}"}},
+		{name: "args of a stage are not expanded", dockerfile: "FROM alpine:3.18\nARG IMAGE=busybox\nCOPY --from=$IMAGE /a /a\n",
+			want: []string{"alpine:3.18", "$IMAGE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := docker.ExtractBaseImages(writeDockerfile(t, tt.dockerfile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(images, tt.want) {
+				t.Fatalf("got %v, want %v", images, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package yaml
 import (
 	"gopkg.in/errgo.v2/errors"
 	corev1 "k8s.io/api/core/v1"
+	"sort"
 	"strings"
 )
 
@@ -45,15 +46,10 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 						container.Args = service.Args
 					}
 					if len(service.Env) > 0 {
-						var envVars []corev1.EnvVar
-						for _, env := range service.Env {
-							envSplit := strings.Split(strings.TrimSpace(env), "=")
-							envVars = append(envVars, corev1.EnvVar{
-								Name:  envSplit[0],
-								Value: envSplit[1],
-							})
-						}
-						container.Env = envVars
+						container.Env = parseEnv(service.Env)
+					}
+					if len(service.SecretEnv) > 0 {
+						container.SecretEnv = parseEnv(service.SecretEnv)
 					}
 					if len(service.Expose) > 0 {
 						var ports []corev1.ContainerPort
@@ -76,6 +72,8 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 					if len(service.ReadyCmd) > 0 {
 						container.ReadyCmd = service.ReadyCmd
 					}
+					container.HealthCheck = service.HealthCheck
+					container.Role = service.Role
 
 					if deployment.Akash.Count != 0 {
 						container.Count = deployment.Akash.Count
@@ -97,25 +95,23 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 				containerNew.Args = service.Args
 			}
 			if len(service.Env) > 0 {
-				var envVars []corev1.EnvVar
-				for _, env := range service.Env {
-					envSplit := strings.Split(strings.TrimSpace(env), "=")
-					envVars = append(envVars, corev1.EnvVar{
-						Name:  envSplit[0],
-						Value: envSplit[1],
-					})
-				}
-				containerNew.Env = envVars
+				containerNew.Env = parseEnv(service.Env)
+			}
+			if len(service.SecretEnv) > 0 {
+				containerNew.SecretEnv = parseEnv(service.SecretEnv)
 			}
 			if len(service.Expose) > 0 {
 				var ports []corev1.ContainerPort
+				var exposes []PortExpose
 				for _, expose := range service.Expose {
 					ports = append(ports, corev1.ContainerPort{
 						ContainerPort: int32(expose.Port),
 						Protocol:      getProtocol(expose.Protocol),
 					})
+					exposes = append(exposes, expose.toPortExpose())
 				}
 				containerNew.Ports = ports
+				containerNew.Exposes = exposes
 			}
 
 			if service.Config.Name != "" && service.Config.Path != "" {
@@ -124,6 +120,8 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 					Path: service.Config.Path,
 				}
 			}
+			containerNew.HealthCheck = service.HealthCheck
+			containerNew.PersistentStorage = service.PersistentStorage
 		}
 
 		containerNew.ResourceLimit = make(corev1.ResourceList)
@@ -133,6 +131,8 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 		if deployment.Lagrange.Count != 0 {
 			containerNew.Count = deployment.Lagrange.Count
 		}
+		containerNew.Autoscale = deployment.Lagrange.Autoscale
+		containerNew.Batch = deployment.Lagrange.Batch
 		containers = append(containers, *containerNew)
 	}
 
@@ -149,23 +149,67 @@ func (dy *DeployYamlV2) ServiceToK8sResource() ([]ContainerResource, error) {
 			result = append(result, c)
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
 
 	return result, nil
 }
 
+// parseEnv parses NAME=VALUE env vars, the value may contain = itself
+func parseEnv(env []string) []corev1.EnvVar {
+	var envVars []corev1.EnvVar
+	for _, e := range env {
+		envSplit := strings.SplitN(strings.TrimSpace(e), "=", 2)
+		envVar := corev1.EnvVar{Name: envSplit[0]}
+		if len(envSplit) > 1 {
+			envVar.Value = envSplit[1]
+		}
+		envVars = append(envVars, envVar)
+	}
+	return envVars
+}
+
 type Service struct {
 	Name      string
 	Image     string   `yaml:"image"`
 	Command   []string `yaml:"command"`
 	Args      []string `yaml:"args"`
 	Env       []string `yaml:"env"`
+	SecretEnv []string `yaml:"secret-env"`
 	Expose    []Expose `yaml:"expose"`
 	DependsOn []string `yaml:"depends-on"`
 	Config    struct {
 		Name string `yaml:"name"`
 		Path string `yaml:"path"`
 	} `yaml:"config"`
-	ReadyCmd []string `yaml:"ready-cmd"`
+	ReadyCmd          []string           `yaml:"ready-cmd"`
+	HealthCheck       *HealthCheck       `yaml:"health-check"`
+	PersistentStorage *PersistentStorage `yaml:"persistent-storage"`
+	// Role is how the service runs when another service depends on it: init runs it to completion before the
+	// dependent service, sidecar (the default) runs it in the same pod, service deploys it separately
+	Role string `yaml:"role"`
+}
+
+// HealthCheck overrides the probes generated for the exposed port of a service.
+// Type is one of http, tcp or exec, durations are in seconds.
+type HealthCheck struct {
+	Type             string   `yaml:"type"`
+	Path             string   `yaml:"path"`
+	Port             int      `yaml:"port"`
+	Command          []string `yaml:"command"`
+	InitialDelay     int      `yaml:"initial-delay"`
+	Period           int      `yaml:"period"`
+	Timeout          int      `yaml:"timeout"`
+	FailureThreshold int      `yaml:"failure-threshold"`
+}
+
+// PersistentStorage requests a volume which is kept across redeploys and renewals of the space.
+// Size and Path default to the values configured by the provider.
+type PersistentStorage struct {
+	Size  string   `yaml:"size"`
+	Path  string   `yaml:"path"`
+	Paths []string `yaml:"-"` // the VOLUME paths of a Dockerfile, each one a sub path of the volume
 }
 
 type Expose struct {
@@ -177,6 +221,26 @@ type Expose struct {
 	Protocol string `yaml:"protocol"`
 }
 
+// toPortExpose decides how the port is exposed. Ports without a protocol or with the http protocol are served over HTTP
+// through the ingress, tcp and udp ports are raw ports.
+func (e Expose) toPortExpose() PortExpose {
+	portExpose := PortExpose{
+		Port:     int32(e.Port),
+		As:       int32(e.As),
+		Protocol: getProtocol(e.Protocol),
+	}
+	switch strings.ToLower(e.Protocol) {
+	case "", "http":
+		portExpose.Http = true
+	}
+	for _, to := range e.To {
+		if to.Global {
+			portExpose.Global = true
+		}
+	}
+	return portExpose
+}
+
 type Profiles struct {
 	Compute map[string]Compute `yaml:"compute"`
 }
@@ -206,14 +270,31 @@ type Deployment struct {
 		Count   int    `yaml:"count"`
 	} `yaml:"akash"`
 	Lagrange struct {
-		Profile string `yaml:"profile"`
-		Count   int    `yaml:"count"`
+		Profile   string     `yaml:"profile"`
+		Count     int        `yaml:"count"`
+		Autoscale *Autoscale `yaml:"autoscale"`
+		Batch     *Batch     `yaml:"batch"`
 	} `yaml:"lagrange"`
 }
 
+// Batch runs a service as a job to completion instead of a long-running deployment. The content of output-path
+// is uploaded as the result of the job, backoff-limit is the number of retries of a failed run.
+type Batch struct {
+	OutputPath   string `yaml:"output-path"`
+	BackoffLimit int    `yaml:"backoff-limit"`
+}
+
+// Autoscale scales the replicas of a service on its CPU utilization, target-cpu is a percentage of the requested CPU.
+// The replicas are bounded by the provider for the hardware tier of the space.
+type Autoscale struct {
+	MinReplicas int `yaml:"min-replicas"`
+	MaxReplicas int `yaml:"max-replicas"`
+	TargetCpu   int `yaml:"target-cpu"`
+}
+
 func getProtocol(proto string) corev1.Protocol {
 	var result corev1.Protocol
-	switch proto {
+	switch strings.ToLower(proto) {
 	case "tcp":
 		result = corev1.ProtocolTCP
 	case "udp":
@@ -0,0 +1,37 @@
+package yaml
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestToPortExpose(t *testing.T) {
+	tests := []struct {
+		name   string
+		expose Expose
+		want   PortExpose
+	}{
+		{name: "unset protocol is http", expose: Expose{Port: 8080},
+			want: PortExpose{Port: 8080, Protocol: corev1.ProtocolTCP, Http: true}},
+		{name: "http protocol", expose: Expose{Port: 8080, As: 80, Protocol: "http"},
+			want: PortExpose{Port: 8080, As: 80, Protocol: corev1.ProtocolTCP, Http: true}},
+		{name: "explicit tcp is raw", expose: Expose{Port: 22, Protocol: "tcp"},
+			want: PortExpose{Port: 22, Protocol: corev1.ProtocolTCP}},
+		{name: "explicit tcp as 80 is raw", expose: Expose{Port: 5432, As: 80, Protocol: "TCP"},
+			want: PortExpose{Port: 5432, As: 80, Protocol: corev1.ProtocolTCP}},
+		{name: "udp is raw", expose: Expose{Port: 27015, Protocol: "UDP"},
+			want: PortExpose{Port: 27015, Protocol: corev1.ProtocolUDP}},
+		{name: "global", expose: Expose{Port: 22, Protocol: "tcp", To: []struct {
+			Global bool `yaml:"global"`
+		}{{Global: true}}},
+			want: PortExpose{Port: 22, Protocol: corev1.ProtocolTCP, Global: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.expose.toPortExpose(); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,18 +8,36 @@ import (
 )
 
 type ContainerResource struct {
-	Name          string
-	Count         int
-	ImageName     string
-	Command       []string
-	Args          []string
-	Env           []corev1.EnvVar
-	Ports         []corev1.ContainerPort
-	ResourceLimit corev1.ResourceList
-	VolumeMounts  ConfigFile
-	Depends       []ContainerResource
-	ReadyCmd      []string
-	GpuModel      string
+	Name              string
+	Count             int
+	ImageName         string
+	Command           []string
+	Args              []string
+	Env               []corev1.EnvVar
+	SecretEnv         []corev1.EnvVar
+	Ports             []corev1.ContainerPort
+	ResourceLimit     corev1.ResourceList
+	VolumeMounts      ConfigFile
+	Depends           []ContainerResource
+	ReadyCmd          []string
+	GpuModel          string
+	HealthCheck       *HealthCheck
+	PersistentStorage *PersistentStorage
+	Exposes           []PortExpose
+	Autoscale         *Autoscale
+	Batch             *Batch
+	Role              string
+}
+
+// PortExpose describes how a container port is reachable from outside the space. Http ports are routed
+// through the ingress, raw ports are published on a NodePort or LoadBalancer service when Global is set
+// and are only reachable inside the cluster otherwise.
+type PortExpose struct {
+	Port     int32
+	As       int32
+	Protocol corev1.Protocol
+	Http     bool
+	Global   bool
 }
 
 type ConfigFile struct {
@@ -81,7 +99,7 @@ func HandlerYaml(yamlFilePath string) ([]ContainerResource, error) {
 			return nil, fmt.Errorf("failed unable to parse YAML file for k8s, %w", err)
 		}
 	default:
-		return nil, fmt.Errorf("not support yaml version: %d", version)
+		return nil, fmt.Errorf("not support yaml version: %s", version)
 	}
 	return containerResources, err
 }